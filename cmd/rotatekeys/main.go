@@ -0,0 +1,43 @@
+// Command rotatekeys publishes a new signing key to S3 and prunes the keys
+// whose tokens have expired. Run it on a schedule, such as a daily cron job.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/rotation"
+	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
+)
+
+func main() {
+	config := rotation.DefaultConfig()
+	pruneOnly := flag.Bool("prune", false, "only remove expired keys, without rotating")
+	flag.StringVar(&config.PublicBucket, "public-bucket", config.PublicBucket, "bucket of the published JWKS")
+	flag.StringVar(&config.JwksKey, "jwks-key", config.JwksKey, "object key of the published JWKS")
+	flag.StringVar(&config.PrivateBucket, "private-bucket", config.PrivateBucket, "bucket of the private JWKs")
+	flag.IntVar(&config.KeyBits, "bits", config.KeyBits, "size of generated RSA keys")
+	flag.DurationVar(&config.PropagationDelay, "propagation-delay", config.PropagationDelay, "how long a new key is published before it signs")
+	flag.DurationVar(&config.TokenTTL, "token-ttl", config.TokenTTL, "longest lifetime of a token signed by a retired key")
+	flag.Parse()
+
+	rotator := rotation.NewRotator(storage.NewS3Store(storage.InitS3Client()), config)
+	ctx := context.Background()
+	if *pruneOnly {
+		removed, err := rotator.Prune(ctx)
+		if err != nil {
+			fmt.Println("prune error: ", err)
+			os.Exit(1)
+		}
+		fmt.Println("removed keys: ", removed)
+		return
+	}
+	kid, err := rotator.Rotate(ctx)
+	if err != nil {
+		fmt.Println("rotate error: ", err)
+		os.Exit(1)
+	}
+	fmt.Println("published key: ", kid)
+}
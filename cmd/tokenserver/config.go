@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	KeySourceMemory = "memory"
+	KeySourceS3     = "s3"
+)
+
+// Config configures the token server. It is read from a YAML or JSON file and
+// then overridden by TOKENSERVER_* environment variables.
+type Config struct {
+	Addr   string `yaml:"addr"`
+	Issuer string `yaml:"issuer"`
+	// KeySource is "memory" for a key generated at startup or "s3" for the
+	// rotated keys published to S3.
+	KeySource        string        `yaml:"key_source"`
+	KeyBits          int           `yaml:"key_bits"`
+	RefreshInterval  time.Duration `yaml:"refresh_interval"`
+	PropagationDelay time.Duration `yaml:"propagation_delay"`
+	JwksMaxAge       time.Duration `yaml:"jwks_max_age"`
+	// RevocationFile persists revocations. They are kept in memory when it
+	// is empty.
+	RevocationFile string `yaml:"revocation_file"`
+	// ClientsFile lists the registered OAuth clients.
+	ClientsFile string `yaml:"clients_file"`
+	// TlsCertFile and TlsKeyFile enable HTTPS. Tokens requested with a
+	// client certificate are then bound to it.
+	TlsCertFile string `yaml:"tls_cert_file"`
+	TlsKeyFile  string `yaml:"tls_key_file"`
+	// TlsClientCAFile verifies client certificates. Without it any client
+	// certificate is accepted, as for self-signed certificate binding.
+	TlsClientCAFile string        `yaml:"tls_client_ca_file"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Addr:             ":8080",
+		Issuer:           "http://localhost:8080",
+		KeySource:        KeySourceMemory,
+		KeyBits:          converter.MinRsaKeyBits,
+		RefreshInterval:  time.Minute,
+		PropagationDelay: 10 * time.Minute,
+		JwksMaxAge:       5 * time.Minute,
+		ShutdownTimeout:  15 * time.Second,
+	}
+}
+
+// LoadConfig reads the config file at path, if any, over the defaults and
+// applies the environment.
+func LoadConfig(path string) (Config, error) {
+	config := DefaultConfig()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+		// YAML is a superset of JSON, so this reads both.
+		err = yaml.Unmarshal(data, &config)
+		if err != nil {
+			return Config{}, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	err := config.applyEnv(os.LookupEnv)
+	if err != nil {
+		return Config{}, err
+	}
+	return config, config.validate()
+}
+
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	stringVars := map[string]*string{
+		"TOKENSERVER_ADDR":               &c.Addr,
+		"TOKENSERVER_ISSUER":             &c.Issuer,
+		"TOKENSERVER_KEY_SOURCE":         &c.KeySource,
+		"TOKENSERVER_REVOCATION_FILE":    &c.RevocationFile,
+		"TOKENSERVER_CLIENTS_FILE":       &c.ClientsFile,
+		"TOKENSERVER_TLS_CERT_FILE":      &c.TlsCertFile,
+		"TOKENSERVER_TLS_KEY_FILE":       &c.TlsKeyFile,
+		"TOKENSERVER_TLS_CLIENT_CA_FILE": &c.TlsClientCAFile,
+	}
+	for name, field := range stringVars {
+		if value, ok := lookup(name); ok {
+			*field = value
+		}
+	}
+	durations := map[string]*time.Duration{
+		"TOKENSERVER_REFRESH_INTERVAL":  &c.RefreshInterval,
+		"TOKENSERVER_PROPAGATION_DELAY": &c.PropagationDelay,
+		"TOKENSERVER_JWKS_MAX_AGE":      &c.JwksMaxAge,
+		"TOKENSERVER_SHUTDOWN_TIMEOUT":  &c.ShutdownTimeout,
+	}
+	for name, field := range durations {
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		*field = duration
+	}
+	if value, ok := lookup("TOKENSERVER_KEY_BITS"); ok {
+		bits, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("TOKENSERVER_KEY_BITS: %w", err)
+		}
+		c.KeyBits = bits
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	if c.Issuer == "" {
+		return fmt.Errorf("issuer is required")
+	}
+	if c.KeySource != KeySourceMemory && c.KeySource != KeySourceS3 {
+		return fmt.Errorf("unknown key_source %q", c.KeySource)
+	}
+	if (c.TlsCertFile == "") != (c.TlsKeyFile == "") {
+		return fmt.Errorf("tls_cert_file and tls_key_file must be set together")
+	}
+	if c.TlsClientCAFile != "" && c.TlsCertFile == "" {
+		return fmt.Errorf("tls_client_ca_file requires tls_cert_file")
+	}
+	if c.RefreshInterval <= 0 {
+		return fmt.Errorf("refresh_interval must be positive")
+	}
+	return nil
+}
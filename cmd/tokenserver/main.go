@@ -0,0 +1,45 @@
+// Command tokenserver issues and manages access tokens over HTTP.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/oauth"
+)
+
+func main() {
+	configPath := flag.String("config", "", "path to a YAML or JSON config file")
+	hashSecret := flag.String("hash-secret", "", "print the secret_hash of a client secret and exit")
+	flag.Parse()
+	if *hashSecret != "" {
+		hash, err := oauth.HashSecret(*hashSecret)
+		if err != nil {
+			fmt.Println("hash error: ", err)
+			os.Exit(1)
+		}
+		fmt.Println(hash)
+		return
+	}
+	config, err := LoadConfig(*configPath)
+	if err != nil {
+		fmt.Println("config error: ", err)
+		os.Exit(1)
+	}
+	server, err := NewServer(config)
+	if err != nil {
+		fmt.Println("startup error: ", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	err = server.Run(ctx)
+	if err != nil {
+		fmt.Println("server error: ", err)
+		os.Exit(1)
+	}
+}
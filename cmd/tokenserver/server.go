@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/dpop"
+	"github.com/CalvinCYCheung/go_token_validator/internal/generator"
+	"github.com/CalvinCYCheung/go_token_validator/internal/jwks"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/oauth"
+	"github.com/CalvinCYCheung/go_token_validator/internal/replay"
+	"github.com/CalvinCYCheung/go_token_validator/internal/revocation"
+	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
+	"github.com/CalvinCYCheung/go_token_validator/internal/validator"
+)
+
+// dpopProofWindow is how far the iat of a DPoP proof may be from now.
+const dpopProofWindow = time.Minute
+
+type Server struct {
+	config     Config
+	generator  *generator.TokenGeneratorImpl
+	validator  *validator.RsaKeyValidator
+	verifier   *oauth.AssertionVerifier
+	httpServer *http.Server
+	ready      atomic.Bool
+}
+
+func NewServer(config Config) (*Server, error) {
+	fetch, pending, err := privateKeyFetch(config)
+	if err != nil {
+		return nil, err
+	}
+	// The only key may have been published just before startup.
+	ctx, cancel := context.WithTimeout(context.Background(), config.PropagationDelay+generator.DefaultActiveKeyWait)
+	defer cancel()
+	tokenGenerator, err := generator.NewTokenGeneratorContext(ctx, config.RefreshInterval, fetch)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		tokenGenerator.SetPendingKeySource(pending)
+	}
+	tokenGenerator.SetIssuer(config.Issuer)
+	// The validator checks tokens against the keys this server publishes. It
+	// refreshes for pending keys and at once when the signing key changes,
+	// so tokens are accepted as soon as they are issued.
+	publicKeys := func() (*model.JWKS, error) {
+		return &model.JWKS{Keys: tokenGenerator.PublicKeys()}, nil
+	}
+	tokenValidator := validator.NewRsaKeyValidator(config.RefreshInterval, publicKeys)
+	tokenValidator.SetIssuer(config.Issuer)
+	tokenGenerator.OnKeysChanged(func(old, current model.KeySetInfo) {
+		jwks, _ := publicKeys()
+		tokenValidator.UpdateJwks(jwks)
+	})
+	clients, err := clientRegistry(config)
+	if err != nil {
+		return nil, err
+	}
+	revocations, err := revocationStore(config, maxTokenTTL(clients))
+	if err != nil {
+		return nil, err
+	}
+	tokenValidator.SetRevocationStore(revocations)
+
+	tokenEndpoint := strings.TrimSuffix(config.Issuer, "/") + "/token"
+	verifier := oauth.NewAssertionVerifier([]string{tokenEndpoint, config.Issuer}, replay.NewMemoryCache())
+	authenticator := oauth.NewClientAuthenticator(clients, verifier)
+
+	tokenHandler := oauth.NewTokenHandler()
+	tokenHandler.SetDPoPVerifier(dpop.NewVerifier(dpopProofWindow, replay.NewMemoryCache()), config.Issuer)
+	tokenHandler.Handle(oauth.GrantTypeClientCredentials, oauth.ClientCredentialsGrant(tokenGenerator, authenticator))
+	exchanger := oauth.NewExchanger(tokenValidator, tokenGenerator)
+	tokenHandler.Handle(oauth.GrantTypeTokenExchange, oauth.TokenExchangeGrant(exchanger, authenticator))
+	metadata := oauth.NewMetadata(config.Issuer, tokenHandler.GrantTypes())
+	metadata.TokenEndpointAuthMethodsSupported = authenticator.Methods()
+	metadata.IntrospectionEndpointAuthMethodsSupported = authenticator.Methods()
+	metadata.DPoPSigningAlgValuesSupported = dpop.Algs
+	if config.TlsCertFile != "" {
+		tokenHandler.SetCertificateBinding(true)
+		metadata.TlsClientCertificateBoundAccessTokens = true
+	}
+
+	server := &Server{
+		config:    config,
+		generator: tokenGenerator,
+		validator: tokenValidator,
+		verifier:  verifier,
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/token", tokenHandler)
+	mux.Handle(jwks.Path, jwks.NewHandler(tokenGenerator, config.JwksMaxAge))
+	mux.Handle(oauth.DiscoveryPath, oauth.NewDiscoveryHandler(metadata))
+	mux.Handle("/introspect", oauth.NewIntrospectionHandler(tokenValidator, authenticator))
+	mux.Handle("/revoke", oauth.NewRevocationHandler(tokenValidator, revocations, nil, authenticator))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("GET /readyz", server.readyz)
+	tlsConfig, err := serverTlsConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	server.httpServer = &http.Server{
+		Addr:      config.Addr,
+		Handler:   mux,
+		TLSConfig: tlsConfig,
+	}
+	server.ready.Store(true)
+	return server, nil
+}
+
+// Run serves until ctx is done and then shuts down gracefully, failing
+// readiness first so load balancers stop sending requests.
+func (s *Server) Run(ctx context.Context) error {
+	defer s.verifier.Close()
+	errs := make(chan error, 1)
+	go func() {
+		fmt.Println("token server listening on ", s.config.Addr)
+		if s.config.TlsCertFile != "" {
+			errs <- s.httpServer.ListenAndServeTLS(s.config.TlsCertFile, s.config.TlsKeyFile)
+			return
+		}
+		errs <- s.httpServer.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	s.ready.Store(false)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+	err := s.httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		return err
+	}
+	err = <-errs
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	if !s.ready.Load() {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
+
+// privateKeyFetch returns the fetch function of the signing key and, for S3,
+// the source of keys that are published but not signing yet.
+func privateKeyFetch(config Config) (func() (*model.PrivateKeyJWK, error), generator.PendingKeySource, error) {
+	if config.KeySource == KeySourceS3 {
+		fetch := generator.NewS3KeyFetch(storage.InitS3Client(), config.PropagationDelay)
+		return fetch.Fetch, fetch, nil
+	}
+	key, err := converter.GenerateRsaKey(config.KeyBits)
+	if err != nil {
+		return nil, nil, err
+	}
+	fetch, err := generator.NewMemoryPrivateKeyFetch(key)
+	return fetch, nil, err
+}
+
+// revocationStore keeps subject revocations for maxTokenTTL, the longest
+// lifetime of a token the server issues.
+func revocationStore(config Config, maxTokenTTL time.Duration) (revocation.RevocationStore, error) {
+	if config.RevocationFile == "" {
+		return revocation.NewMemoryStore(maxTokenTTL), nil
+	}
+	return revocation.NewFileStore(config.RevocationFile, maxTokenTTL)
+}
+
+// maxTokenTTL is the longest lifetime of the tokens issued to clients, which
+// may set a TokenTTL of their own.
+func maxTokenTTL(clients *oauth.MemoryClientRegistry) time.Duration {
+	return max(generator.TokenTTL, oauth.DefaultExchangeTTL, clients.MaxTokenTTL())
+}
+
+func clientRegistry(config Config) (*oauth.MemoryClientRegistry, error) {
+	if config.ClientsFile == "" {
+		return oauth.NewMemoryClientRegistry(), nil
+	}
+	return oauth.LoadClientRegistry(config.ClientsFile)
+}
+
+// serverTlsConfig requests client certificates so tokens can be bound to
+// them. Clients without a certificate can still connect.
+func serverTlsConfig(config Config) (*tls.Config, error) {
+	if config.TlsCertFile == "" {
+		return nil, nil
+	}
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequestClientCert,
+	}
+	if config.TlsClientCAFile != "" {
+		pem, err := os.ReadFile(config.TlsClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s: no certificates found", config.TlsClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
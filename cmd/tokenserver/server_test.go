@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/oauth"
+)
+
+const (
+	testClientID     = "orders"
+	testClientSecret = "orders-secret"
+)
+
+// newTestServer serves a server with in-memory keys and two clients that
+// authenticate with client_secret_basic.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	hash, err := oauth.HashSecret(testClientSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clients := fmt.Sprintf(`clients:
+  - id: %s
+    secret_hash: %q
+    scopes: [orders:read]
+  - id: billing
+    secret_hash: %q
+    scopes: [billing:read]
+`, testClientID, hash, hash)
+	config := DefaultConfig()
+	config.ClientsFile = filepath.Join(t.TempDir(), "clients.yaml")
+	err = os.WriteFile(config.ClientsFile, []byte(clients), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := NewServer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := httptest.NewServer(server.httpServer.Handler)
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// post sends an authenticated form request and decodes the JSON response.
+func post(t *testing.T, ts *httptest.Server, path string, clientID string, form url.Values, out any) int {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodPost, ts.URL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if clientID != "" {
+		req.SetBasicAuth(clientID, testClientSecret)
+	}
+	res, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if out != nil && res.StatusCode == http.StatusOK {
+		err = json.NewDecoder(res.Body).Decode(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return res.StatusCode
+}
+
+func introspect(t *testing.T, ts *httptest.Server, token string) *oauth.IntrospectionResponse {
+	t.Helper()
+	var response oauth.IntrospectionResponse
+	status := post(t, ts, "/introspect", testClientID, url.Values{"token": {token}}, &response)
+	if status != http.StatusOK {
+		t.Fatalf("introspect status = %d", status)
+	}
+	return &response
+}
+
+func TestIssueIntrospectRevoke(t *testing.T) {
+	ts := newTestServer(t)
+
+	var token oauth.TokenResponse
+	status := post(t, ts, "/token", testClientID, url.Values{"grant_type": {oauth.GrantTypeClientCredentials}}, &token)
+	if status != http.StatusOK {
+		t.Fatalf("token status = %d", status)
+	}
+	active := introspect(t, ts, token.AccessToken)
+	if !active.Active || active.ClientID != testClientID || active.Scope != "orders:read" {
+		t.Fatalf("introspection = %+v, want an active orders token", active)
+	}
+
+	form := url.Values{"token": {token.AccessToken}}
+	if status := post(t, ts, "/revoke", "", form, nil); status != http.StatusUnauthorized {
+		t.Errorf("unauthenticated revoke status = %d, want 401", status)
+	}
+	if status := post(t, ts, "/revoke", "billing", form, nil); status != http.StatusBadRequest {
+		t.Errorf("revoke by another client status = %d, want 400", status)
+	}
+	if !introspect(t, ts, token.AccessToken).Active {
+		t.Fatal("token inactive before its client revoked it")
+	}
+	if status := post(t, ts, "/revoke", testClientID, form, nil); status != http.StatusOK {
+		t.Fatalf("revoke status = %d", status)
+	}
+	if introspect(t, ts, token.AccessToken).Active {
+		t.Error("revoked token is still active")
+	}
+}
+
+func TestDiscoveryOmitsRefreshTokens(t *testing.T) {
+	ts := newTestServer(t)
+	res, err := ts.Client().Get(ts.URL + oauth.DiscoveryPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	var metadata oauth.Metadata
+	err = json.NewDecoder(res.Body).Decode(&metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, grantType := range metadata.GrantTypesSupported {
+		if grantType == oauth.GrantTypeRefreshToken {
+			t.Errorf("discovery advertises %s, which the server cannot issue", grantType)
+		}
+	}
+}
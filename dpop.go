@@ -0,0 +1,28 @@
+package tokenservice
+
+import (
+	"crypto"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/dpop"
+	"github.com/CalvinCYCheung/go_token_validator/internal/replay"
+)
+
+type DPoPVerifier = dpop.Verifier
+
+// NewDPoPVerifier returns a verifier of DPoP proofs issued within window of
+// now, remembering their jti in memory.
+func NewDPoPVerifier(window time.Duration) *DPoPVerifier {
+	return dpop.NewVerifier(window, replay.NewMemoryCache())
+}
+
+// NewDPoPProof creates the DPoP header value for a request with method to url
+// carrying accessToken, which is empty at the token endpoint.
+func NewDPoPProof(key crypto.Signer, method string, url string, accessToken string) (string, error) {
+	return dpop.NewProof(key, method, url, accessToken)
+}
+
+// DPoPThumbprint returns the jkt that tokens bound to key carry.
+func DPoPThumbprint(key crypto.Signer) (string, error) {
+	return dpop.Thumbprint(key)
+}
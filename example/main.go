@@ -9,7 +9,9 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/CalvinCYCheung/go_token_validator/ginauth"
 	"github.com/CalvinCYCheung/go_token_validator/internal/generator"
+	"github.com/CalvinCYCheung/go_token_validator/internal/jwks"
 	"github.com/CalvinCYCheung/go_token_validator/internal/model"
 	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
 	"github.com/CalvinCYCheung/go_token_validator/internal/validator"
@@ -26,6 +28,7 @@ func main() {
 		return &model.JWKS{}, nil
 	})
 	router := gin.Default()
+	router.GET(jwks.Path, gin.WrapH(jwks.NewHandler(tokenGenerator, 5*time.Minute)))
 	router.POST("/token", func(ctx *gin.Context) {
 		token, err := tokenGenerator.Generate()
 		if err != nil {
@@ -35,25 +38,13 @@ func main() {
 		}
 		ctx.JSON(200, gin.H{"token": token})
 	})
-	router.POST("/validate", func(c *gin.Context) {
-		token := c.GetHeader("Authorization")
-		if token == "" {
-			c.JSON(400, gin.H{"error": "Token is required"})
-			c.Abort()
-			return
-		}
-		isValid, err := validator.Validate(token)
-		if err != nil {
-			c.JSON(500, gin.H{"error": err.Error()})
-			c.Abort()
-			return
-		}
-		if !isValid {
-			c.JSON(401, gin.H{"error": "Invalid token"})
-			c.Abort()
-			return
-		}
-		c.JSON(200, gin.H{"isValid": isValid})
+	authorized := router.Group("/", ginauth.Authenticate(validator, "example"))
+	authorized.POST("/validate", func(c *gin.Context) {
+		claims, _ := ginauth.Claims(c)
+		c.JSON(200, gin.H{"isValid": true, "sub": claims.Subject})
+	})
+	authorized.POST("/orders", ginauth.RequireScopes("orders:write"), func(c *gin.Context) {
+		c.JSON(200, gin.H{"created": true})
 	})
 	router.Run(":5080")
 	// tokenGenerator := generator.NewTokenGenerator(15 * time.Minute)
@@ -0,0 +1,120 @@
+// Package ginauth authenticates gin requests with tokens validated by a
+// tokenservice validator and enforces per-route scopes, roles and policies.
+package ginauth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/middleware"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/policy"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	claimsKey = "tokenservice.claims"
+	realmKey  = "tokenservice.realm"
+)
+
+// Authenticate validates the token read by extractors, or the Authorization
+// Bearer header when none are given, and stores its claims in both the gin
+// context and the request context.
+func Authenticate(validator middleware.ClaimsValidator, realm string, extractors ...middleware.Extractor) gin.HandlerFunc {
+	extractor := middleware.BearerHeader()
+	if len(extractors) > 0 {
+		extractor = middleware.Chain(extractors...)
+	}
+	return func(c *gin.Context) {
+		c.Set(realmKey, realm)
+		token, err := extractor.Extract(c.Request)
+		if errors.Is(err, middleware.ErrMissingToken) {
+			abort(c, http.StatusUnauthorized, "", "", "")
+			return
+		}
+		if err != nil {
+			abort(c, http.StatusBadRequest, middleware.ErrorInvalidRequest, err.Error(), "")
+			return
+		}
+		claims, err := middleware.ValidateClaims(c.Request.Context(), validator, token)
+		if err != nil {
+			abort(c, http.StatusUnauthorized, middleware.ErrorInvalidToken, err.Error(), "")
+			return
+		}
+		err = middleware.CheckBearerBinding(claims, c.Request.TLS)
+		if err != nil {
+			abort(c, http.StatusUnauthorized, middleware.ErrorInvalidToken, err.Error(), "")
+			return
+		}
+		c.Set(claimsKey, claims)
+		c.Request = c.Request.WithContext(middleware.WithClaims(c.Request.Context(), claims))
+		c.Next()
+	}
+}
+
+// Claims returns the claims stored by Authenticate.
+func Claims(c *gin.Context) (*model.JwtClaim, bool) {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*model.JwtClaim)
+	return claims, ok
+}
+
+// RequireScopes rejects tokens that lack any of scopes.
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return require(strings.Join(scopes, " "), func(claims *model.JwtClaim) bool {
+		return claims.HasAllScopes(scopes...)
+	})
+}
+
+// RequireRoles rejects tokens that have none of roles.
+func RequireRoles(roles ...string) gin.HandlerFunc {
+	return require("", func(claims *model.JwtClaim) bool {
+		return claims.HasAnyRole(roles...)
+	})
+}
+
+// RequirePolicy rejects tokens whose claims fail p. The gin path parameters
+// are available to rules as request parameters.
+func RequirePolicy(p *policy.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := Claims(c)
+		if !ok {
+			abort(c, http.StatusUnauthorized, "", "", "")
+			return
+		}
+		params := make(map[string]string, len(c.Params))
+		for _, param := range c.Params {
+			params[param.Key] = param.Value
+		}
+		err := p.Evaluate(policy.Input{Claims: claims, Params: params})
+		if err != nil {
+			abort(c, http.StatusForbidden, middleware.ErrorInsufficientScope, err.Error(), "")
+			return
+		}
+		c.Next()
+	}
+}
+
+func require(scope string, check func(claims *model.JwtClaim) bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := Claims(c)
+		if !ok {
+			abort(c, http.StatusUnauthorized, "", "", "")
+			return
+		}
+		if !check(claims) {
+			abort(c, http.StatusForbidden, middleware.ErrorInsufficientScope, "token does not grant the required access", scope)
+			return
+		}
+		c.Next()
+	}
+}
+
+func abort(c *gin.Context, status int, code string, description string, scope string) {
+	middleware.WriteChallenge(c.Writer, c.GetString(realmKey), status, code, description, scope)
+	c.Abort()
+}
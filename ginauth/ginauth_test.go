@@ -0,0 +1,113 @@
+package ginauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/middleware"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/policy"
+	"github.com/CalvinCYCheung/go_token_validator/internal/testutil"
+	"github.com/gin-gonic/gin"
+)
+
+func parseClaims(t *testing.T, raw string) *model.JwtClaim {
+	t.Helper()
+	claims := &model.JwtClaim{}
+	err := json.Unmarshal([]byte(raw), claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestGinAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	validator := testutil.StubValidator{
+		"reader": parseClaims(t, `{"sub":"user-1","scope":"orders:read","roles":["viewer"],"tenant":"acme"}`),
+		"admin":  parseClaims(t, `{"sub":"user-2","scope":"orders:read orders:write","roles":["admin"],"tenant":"globex"}`),
+		"dpop":   parseClaims(t, `{"sub":"user-3","cnf":{"jkt":"thumbprint"}}`),
+	}
+	tenantPolicy := policy.New(policy.EqualsParam("tenant", "tenant"))
+	tests := []struct {
+		name          string
+		guards        []gin.HandlerFunc
+		authorization string
+		wantStatus    int
+		wantChallenge string
+		wantSubject   string
+	}{
+		{"valid token", nil, "Bearer reader", http.StatusOK, "", "user-1"},
+		{"no credentials", nil, "", http.StatusUnauthorized, `Bearer realm="orders"`, ""},
+		{"malformed header", nil, "Bearer reader extra", http.StatusBadRequest, `Bearer realm="orders", error="invalid_request"`, ""},
+		{"invalid token", nil, "Bearer forged", http.StatusUnauthorized, `Bearer realm="orders", error="invalid_token"`, ""},
+		{"dpop token as bearer", nil, "Bearer dpop", http.StatusUnauthorized, `Bearer realm="orders", error="invalid_token"`, ""},
+		{"granted scope", []gin.HandlerFunc{RequireScopes("orders:read")}, "Bearer reader", http.StatusOK, "", "user-1"},
+		{"missing scope", []gin.HandlerFunc{RequireScopes("orders:read", "orders:write")}, "Bearer reader", http.StatusForbidden,
+			`Bearer realm="orders", error="insufficient_scope", error_description="token does not grant the required access", scope="orders:read orders:write"`, ""},
+		{"granted role", []gin.HandlerFunc{RequireRoles("admin", "viewer")}, "Bearer reader", http.StatusOK, "", "user-1"},
+		{"missing role", []gin.HandlerFunc{RequireRoles("admin")}, "Bearer reader", http.StatusForbidden, `Bearer realm="orders", error="insufficient_scope"`, ""},
+		{"policy passes", []gin.HandlerFunc{RequirePolicy(tenantPolicy)}, "Bearer reader", http.StatusOK, "", "user-1"},
+		{"policy fails", []gin.HandlerFunc{RequirePolicy(tenantPolicy)}, "Bearer admin", http.StatusForbidden, `Bearer realm="orders", error="insufficient_scope"`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var subject, contextSubject string
+			handlers := []gin.HandlerFunc{Authenticate(validator, "orders")}
+			handlers = append(handlers, tt.guards...)
+			handlers = append(handlers, func(c *gin.Context) {
+				if claims, ok := Claims(c); ok {
+					subject = claims.Subject
+				}
+				if claims, ok := middleware.ClaimsFromContext(c.Request.Context()); ok {
+					contextSubject = claims.Subject
+				}
+				c.Status(http.StatusOK)
+			})
+			router := gin.New()
+			router.GET("/tenants/:tenant/orders", handlers...)
+
+			r := httptest.NewRequest(http.MethodGet, "/tenants/acme/orders", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			challenge := w.Header().Get("WWW-Authenticate")
+			if !strings.HasPrefix(challenge, tt.wantChallenge) {
+				t.Errorf("WWW-Authenticate = %q, want prefix %q", challenge, tt.wantChallenge)
+			}
+			if tt.wantChallenge == "" && challenge != "" {
+				t.Errorf("WWW-Authenticate = %q on success", challenge)
+			}
+			if subject != tt.wantSubject || contextSubject != tt.wantSubject {
+				t.Errorf("subject = %q in the gin context and %q in the request context, want %q", subject, contextSubject, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestGuardsWithoutAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for name, guard := range map[string]gin.HandlerFunc{
+		"RequireScopes": RequireScopes("orders:read"),
+		"RequireRoles":  RequireRoles("admin"),
+		"RequirePolicy": RequirePolicy(policy.New(policy.Exists("sub"))),
+	} {
+		t.Run(name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", guard, func(c *gin.Context) { c.Status(http.StatusOK) })
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status = %d, want 401", w.Code)
+			}
+		})
+	}
+}
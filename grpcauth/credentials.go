@@ -0,0 +1,89 @@
+package grpcauth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenSource provides access tokens, such as a
+// tokenservice.ClientCredentialsSource fetching them from the token endpoint.
+type TokenSource interface {
+	Generate() (string, error)
+}
+
+// ContextTokenSource is implemented by sources that do I/O, such as
+// tokenservice.ClientCredentialsSource, so they can stop when the RPC is
+// cancelled.
+type ContextTokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// PerRPCCredentials attaches a token from source to every RPC and mints a new
+// one refreshBefore the current token expires.
+type PerRPCCredentials struct {
+	mu            sync.Mutex
+	source        TokenSource
+	refreshBefore time.Duration
+	requireTLS    bool
+	token         string
+	expiry        time.Time
+}
+
+func NewPerRPCCredentials(source TokenSource, refreshBefore time.Duration, requireTLS bool) *PerRPCCredentials {
+	return &PerRPCCredentials{
+		source:        source,
+		refreshBefore: refreshBefore,
+		requireTLS:    requireTLS,
+	}
+}
+
+func (c *PerRPCCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	token, err := c.currentToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"authorization": "Bearer " + token}, nil
+}
+
+func (c *PerRPCCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
+
+// currentToken returns the cached token or a new one from the source. The
+// source is called without holding the lock, so a slow source does not keep
+// RPCs whose context is done waiting.
+func (c *PerRPCCredentials) currentToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	if c.token != "" && time.Now().Before(c.expiry.Add(-c.refreshBefore)) {
+		token := c.token
+		c.mu.Unlock()
+		return token, nil
+	}
+	c.mu.Unlock()
+	var token string
+	var err error
+	if source, ok := c.source.(ContextTokenSource); ok {
+		token, err = source.Token(ctx)
+	} else {
+		token, err = c.source.Generate()
+	}
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.RegisteredClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(token, &claims)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+	c.expiry = time.Time{}
+	if claims.ExpiresAt != nil {
+		c.expiry = claims.ExpiresAt.Time
+	}
+	return token, nil
+}
@@ -0,0 +1,102 @@
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// countingSource mints unsigned tokens expiring after ttl and counts them.
+type countingSource struct {
+	ttl   time.Duration
+	calls int
+}
+
+func (s *countingSource) Generate() (string, error) {
+	s.calls++
+	token := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		ID:        strconv.Itoa(s.calls),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(s.ttl)),
+	})
+	return token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+}
+
+// contextSource records the context it was asked for a token with.
+type contextSource struct {
+	countingSource
+	ctx context.Context
+}
+
+func (s *contextSource) Token(ctx context.Context) (string, error) {
+	s.ctx = ctx
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	return s.Generate()
+}
+
+func TestPerRPCCredentialsRefresh(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		wantCalls int
+	}{
+		{"cached until refreshBefore", time.Hour, 1},
+		{"refreshed within refreshBefore", 30 * time.Second, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &countingSource{ttl: tt.ttl}
+			creds := NewPerRPCCredentials(source, time.Minute, true)
+			first, err := creds.GetRequestMetadata(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			second, err := creds.GetRequestMetadata(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if source.calls != tt.wantCalls {
+				t.Errorf("tokens minted = %d, want %d", source.calls, tt.wantCalls)
+			}
+			if (first["authorization"] == second["authorization"]) != (tt.wantCalls == 1) {
+				t.Errorf("authorization %q then %q", first["authorization"], second["authorization"])
+			}
+			if !strings.HasPrefix(first["authorization"], "Bearer ") {
+				t.Errorf("authorization = %q, want a Bearer token", first["authorization"])
+			}
+		})
+	}
+}
+
+func TestPerRPCCredentialsPassesContext(t *testing.T) {
+	source := &contextSource{countingSource: countingSource{ttl: time.Hour}}
+	creds := NewPerRPCCredentials(source, time.Minute, true)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := creds.GetRequestMetadata(ctx)
+	if !errors.Is(err, context.Canceled) || source.ctx != ctx {
+		t.Fatalf("error = %v, want the RPC context to reach the source", err)
+	}
+	_, err = creds.GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if source.calls != 1 {
+		t.Errorf("tokens minted = %d, want 1", source.calls)
+	}
+}
+
+func TestPerRPCCredentialsRequireTransportSecurity(t *testing.T) {
+	for _, requireTLS := range []bool{true, false} {
+		creds := NewPerRPCCredentials(&countingSource{ttl: time.Hour}, time.Minute, requireTLS)
+		if got := creds.RequireTransportSecurity(); got != requireTLS {
+			t.Errorf("RequireTransportSecurity() = %v, want %v", got, requireTLS)
+		}
+	}
+}
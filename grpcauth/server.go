@@ -0,0 +1,133 @@
+// Package grpcauth authenticates gRPC calls with tokens validated by a
+// tokenservice validator and attaches tokens to outgoing calls.
+package grpcauth
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"strings"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/middleware"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Authorizer decides whether claims may call fullMethod. Returning an error
+// wrapping middleware.ErrInsufficientScope maps to codes.PermissionDenied.
+type Authorizer func(ctx context.Context, fullMethod string, claims *model.JwtClaim) error
+
+// RequireScopes returns an Authorizer that requires all scopes listed for a
+// full method name. Methods not listed only need a valid token.
+func RequireScopes(methodScopes map[string][]string) Authorizer {
+	return func(ctx context.Context, fullMethod string, claims *model.JwtClaim) error {
+		for _, scope := range methodScopes[fullMethod] {
+			if !claims.HasScope(scope) {
+				return status.Errorf(codes.PermissionDenied, "%s: %s", middleware.ErrInsufficientScope, scope)
+			}
+		}
+		return nil
+	}
+}
+
+func UnaryServerInterceptor(validator middleware.ClaimsValidator, authorize Authorizer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, info.FullMethod, validator, authorize)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamServerInterceptor(validator middleware.ClaimsValidator, authorize Authorizer) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), info.FullMethod, validator, authorize)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(
+	ctx context.Context,
+	fullMethod string,
+	validator middleware.ClaimsValidator,
+	authorize Authorizer,
+) (context.Context, error) {
+	token, err := tokenFromMetadata(ctx)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	claims, err := middleware.ValidateClaims(ctx, validator, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	err = middleware.CheckBearerBinding(claims, peerTLSState(ctx))
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if authorize != nil {
+		err = authorize(ctx, fullMethod, claims)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+	}
+	return middleware.WithClaims(ctx, claims), nil
+}
+
+// peerTLSState returns the TLS state of the connection of ctx, or nil when
+// the connection does not use TLS.
+func peerTLSState(ctx context.Context) *tls.ConnectionState {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	return &info.State
+}
+
+func toStatus(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, middleware.ErrInsufficientScope) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.Unauthenticated, err.Error())
+}
+
+func tokenFromMetadata(ctx context.Context) (string, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return "", middleware.ErrMissingToken
+	}
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return "", middleware.ErrMissingToken
+	}
+	scheme, token, _ := strings.Cut(values[0], " ")
+	token = strings.TrimSpace(token)
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", middleware.ErrMalformedToken
+	}
+	return token, nil
+}
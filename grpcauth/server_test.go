@@ -0,0 +1,126 @@
+package grpcauth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/middleware"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/testutil"
+	"github.com/golang-jwt/jwt/v5"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// callContext returns the context of an incoming call with a bearer token,
+// made over mutual TLS with certs or over a plain connection when nil.
+func callContext(certs []*x509.Certificate) context.Context {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token"))
+	p := &peer.Peer{}
+	if certs != nil {
+		p.AuthInfo = credentials.TLSInfo{State: tls.ConnectionState{PeerCertificates: certs}}
+	}
+	return peer.NewContext(ctx, p)
+}
+
+func TestUnaryServerInterceptorChecksCertificateBinding(t *testing.T) {
+	cert := testutil.NewClientCert(t, "orders")
+	other := testutil.NewClientCert(t, "billing")
+	bound := &model.JwtClaim{Cnf: &model.Confirmation{X5tS256: model.CertificateThumbprint(cert)}}
+	tests := []struct {
+		name     string
+		claims   *model.JwtClaim
+		certs    []*x509.Certificate
+		wantCode codes.Code
+	}{
+		{"bound with its certificate", bound, []*x509.Certificate{cert}, codes.OK},
+		{"bound with another certificate", bound, []*x509.Certificate{other}, codes.Unauthenticated},
+		{"bound without a certificate", bound, []*x509.Certificate{}, codes.Unauthenticated},
+		{"bound without tls", bound, nil, codes.Unauthenticated},
+		{"unbound without tls", &model.JwtClaim{}, nil, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor := UnaryServerInterceptor(testutil.StubValidator{"token": tt.claims}, nil)
+			info := &grpc.UnaryServerInfo{FullMethod: "/orders.Orders/Get"}
+			_, err := interceptor(callContext(tt.certs), nil, info, func(ctx context.Context, req any) (any, error) {
+				return nil, nil
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Errorf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+		})
+	}
+}
+
+// fakeStream is a server stream with a fixed context.
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s fakeStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestServerInterceptors(t *testing.T) {
+	validator := testutil.StubValidator{
+		"reader": {Scope: "orders:read", RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"}},
+	}
+	authorize := RequireScopes(map[string][]string{"/orders.Orders/Get": {"orders:read"}, "/orders.Orders/Delete": {"orders:write"}})
+	withAuthorization := func(value string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", value))
+	}
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		method   string
+		wantCode codes.Code
+	}{
+		{"granted scope", withAuthorization("Bearer reader"), "/orders.Orders/Get", codes.OK},
+		{"lower case scheme", withAuthorization("bearer reader"), "/orders.Orders/Get", codes.OK},
+		{"method without scopes", withAuthorization("Bearer reader"), "/orders.Orders/List", codes.OK},
+		{"missing scope", withAuthorization("Bearer reader"), "/orders.Orders/Delete", codes.PermissionDenied},
+		{"missing metadata", context.Background(), "/orders.Orders/Get", codes.Unauthenticated},
+		{"missing authorization", metadata.NewIncomingContext(context.Background(), metadata.Pairs("other", "value")), "/orders.Orders/Get", codes.Unauthenticated},
+		{"wrong scheme", withAuthorization("Basic reader"), "/orders.Orders/Get", codes.Unauthenticated},
+		{"empty token", withAuthorization("Bearer "), "/orders.Orders/Get", codes.Unauthenticated},
+		{"invalid token", withAuthorization("Bearer forged"), "/orders.Orders/Get", codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/unary", func(t *testing.T) {
+			interceptor := UnaryServerInterceptor(validator, authorize)
+			var claims *model.JwtClaim
+			_, err := interceptor(tt.ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req any) (any, error) {
+				claims, _ = middleware.ClaimsFromContext(ctx)
+				return nil, nil
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			if tt.wantCode == codes.OK && (claims == nil || claims.Subject != "user-1") {
+				t.Errorf("claims = %+v, want the claims of user-1", claims)
+			}
+		})
+		t.Run(tt.name+"/stream", func(t *testing.T) {
+			interceptor := StreamServerInterceptor(validator, authorize)
+			var claims *model.JwtClaim
+			err := interceptor(nil, fakeStream{ctx: tt.ctx}, &grpc.StreamServerInfo{FullMethod: tt.method}, func(srv any, stream grpc.ServerStream) error {
+				claims, _ = middleware.ClaimsFromContext(stream.Context())
+				return nil
+			})
+			if code := status.Code(err); code != tt.wantCode {
+				t.Fatalf("code = %v, want %v: %v", code, tt.wantCode, err)
+			}
+			if tt.wantCode == codes.OK && (claims == nil || claims.Subject != "user-1") {
+				t.Errorf("claims = %+v, want the claims of user-1", claims)
+			}
+		})
+	}
+}
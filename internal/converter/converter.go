@@ -1,24 +1,23 @@
 package converter
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
-	"encoding/pem"
-	"errors"
-	"fmt"
-	"math/big"
 
 	"github.com/CalvinCYCheung/go_token_validator/internal/model"
 )
 
 type SupportedReturnTypes interface {
-	*rsa.PublicKey | *rsa.PrivateKey | string
+	*rsa.PublicKey | *rsa.PrivateKey | string | model.PrivateKeyJWK | model.PublicKeyJWK
 }
 
 type SupportedParamsTypes interface {
-	[]byte | model.PrivateKeyJWK | model.PublicKeyJWK
+	[]byte | model.PrivateKeyJWK | model.PublicKeyJWK |
+		*rsa.PublicKey | *rsa.PrivateKey |
+		*ecdsa.PublicKey | *ecdsa.PrivateKey |
+		ed25519.PublicKey | ed25519.PrivateKey
 }
 
 type Converter[R SupportedReturnTypes, P SupportedParamsTypes] interface {
@@ -34,15 +33,7 @@ func (str StringConverter) Convert(data []byte) (string, error) {
 type RsaPublicKeyConverter struct{}
 
 func (converter RsaPublicKeyConverter) Convert(data []byte) (*rsa.PublicKey, error) {
-	block, _ := pem.Decode(data)
-	if block == nil {
-		return nil, errors.New("invalid pem block")
-	}
-	key, err := x509.ParsePKCS1PublicKey(block.Bytes)
-	if err != nil {
-		return nil, err
-	}
-	return key, nil
+	return parseRsaPublicKeyPem(data)
 }
 
 type PublicKeyJwkToKeyConverter struct{}
@@ -53,34 +44,16 @@ func (converter PublicKeyJwkToKeyConverter) Convert(data []byte) (*rsa.PublicKey
 	if err != nil {
 		return nil, err
 	}
-	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
-	if err != nil {
-		return nil, err
-	}
-	e, err := base64.RawStdEncoding.DecodeString(jwk.E)
-	if err != nil {
-		return nil, err
-	}
-	key := &rsa.PublicKey{
-		N: big.NewInt(0).SetBytes(n),
-		E: int(big.NewInt(0).SetBytes(e).Int64()),
-	}
-	return key, nil
+	return ParseRsaPublicJwk(jwk)
 }
 
-type RsaPrivateKeyConverter struct{}
+type RsaPrivateKeyConverter struct {
+	// Password decrypts ENCRYPTED PRIVATE KEY blocks.
+	Password []byte
+}
 
 func (converter RsaPrivateKeyConverter) Convert(data []byte) (*rsa.PrivateKey, error) {
-	block, _ := pem.Decode(data)
-	if block == nil {
-		return nil, errors.New("invalid pem block")
-	}
-
-	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
-	if err != nil {
-		return nil, err
-	}
-	return key, nil
+	return parseRsaPrivateKeyPem(data, converter.Password)
 }
 
 type PrivateKeyJwkToKeyConverter struct{}
@@ -91,101 +64,17 @@ func (converter PrivateKeyJwkToKeyConverter) Convert(data []byte) (*rsa.PrivateK
 	if err != nil {
 		return nil, err
 	}
-	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
-	if err != nil {
-		return nil, err
-	}
-	e, err := base64.RawStdEncoding.DecodeString(jwk.E)
-	if err != nil {
-		return nil, err
-	}
-
-	p, err := base64.RawURLEncoding.DecodeString(jwk.P)
-	if err != nil {
-		return nil, err
-	}
-	q, err := base64.RawURLEncoding.DecodeString(jwk.Q)
-	if err != nil {
-		return nil, err
-	}
-	d, err := base64.RawURLEncoding.DecodeString(jwk.D)
-	if err != nil {
-		return nil, err
-	}
-	key := &rsa.PrivateKey{
-		D: big.NewInt(0).SetBytes(d),
-		PublicKey: rsa.PublicKey{
-			N: big.NewInt(0).SetBytes(n),
-			E: int(big.NewInt(0).SetBytes(e).Int64()),
-		},
-		Primes: []*big.Int{
-			big.NewInt(0).SetBytes(p),
-			big.NewInt(0).SetBytes(q),
-		},
-	}
-	key.Precompute()
-	return key, key.Validate()
+	return ParseRsaPrivateJwk(jwk)
 }
 
 type JwkToPrivateKeyConverter struct{}
 
 func (converter JwkToPrivateKeyConverter) Convert(jwk model.PrivateKeyJWK) (*rsa.PrivateKey, error) {
-
-	d, err := base64.RawURLEncoding.DecodeString(jwk.D)
-	if err != nil {
-		fmt.Println("d", err)
-		return nil, err
-	}
-	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
-	if err != nil {
-		fmt.Println("n", err)
-		return nil, err
-	}
-	e, err := base64.RawURLEncoding.DecodeString(jwk.E)
-	if err != nil {
-		fmt.Println("e", err)
-		return nil, err
-	}
-	p, err := base64.RawURLEncoding.DecodeString(jwk.P)
-	if err != nil {
-		fmt.Println("p", err)
-		return nil, err
-	}
-	q, err := base64.RawURLEncoding.DecodeString(jwk.Q)
-	if err != nil {
-		fmt.Println("q", err)
-		return nil, err
-	}
-
-	key := &rsa.PrivateKey{
-		D: big.NewInt(0).SetBytes(d),
-		PublicKey: rsa.PublicKey{
-			N: big.NewInt(0).SetBytes(n),
-			E: int(big.NewInt(0).SetBytes(e).Int64()),
-		},
-		Primes: []*big.Int{
-			big.NewInt(0).SetBytes(p),
-			big.NewInt(0).SetBytes(q),
-		},
-	}
-	key.Precompute()
-	return key, key.Validate()
+	return ParseRsaPrivateJwk(jwk)
 }
 
 type JwkToPublicKeyConverter struct{}
 
 func (converter JwkToPublicKeyConverter) Convert(jwk model.PublicKeyJWK) (*rsa.PublicKey, error) {
-	n, err := base64.RawURLEncoding.DecodeString(jwk.N)
-	if err != nil {
-		return nil, err
-	}
-	e, err := base64.RawStdEncoding.DecodeString(jwk.E)
-	if err != nil {
-		return nil, err
-	}
-	key := &rsa.PublicKey{
-		N: big.NewInt(0).SetBytes(n),
-		E: int(big.NewInt(0).SetBytes(e).Int64()),
-	}
-	return key, nil
+	return ParseRsaPublicJwk(jwk)
 }
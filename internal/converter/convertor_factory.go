@@ -1,6 +1,8 @@
 package converter
 
 import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 
 	"github.com/CalvinCYCheung/go_token_validator/internal/model"
@@ -23,6 +25,10 @@ func (factory ConvertFactory) CreateRsaPublicKeyConverter() Converter[*rsa.Publi
 	return RsaPublicKeyConverter{}
 }
 
+func (factory ConvertFactory) CreateRsaPrivateKeyConverter(password []byte) Converter[*rsa.PrivateKey, []byte] {
+	return RsaPrivateKeyConverter{Password: password}
+}
+
 func (factory ConvertFactory) CreateJwkToPublicKeyConverter() Converter[*rsa.PublicKey, []byte] {
 	return PublicKeyJwkToKeyConverter{}
 }
@@ -34,3 +40,27 @@ func (factory ConvertFactory) JwkToPrivateConverter() Converter[*rsa.PrivateKey,
 func (factory ConvertFactory) JwkToPublicConverter() Converter[*rsa.PublicKey, model.PublicKeyJWK] {
 	return JwkToPublicKeyConverter{}
 }
+
+func (factory ConvertFactory) PublicKeyToJwkConverter() Converter[model.PublicKeyJWK, *rsa.PublicKey] {
+	return PublicKeyToJwkConverter{}
+}
+
+func (factory ConvertFactory) PrivateKeyToJwkConverter() Converter[model.PrivateKeyJWK, *rsa.PrivateKey] {
+	return PrivateKeyToJwkConverter{}
+}
+
+func (factory ConvertFactory) EcdsaPublicKeyToJwkConverter() Converter[model.PublicKeyJWK, *ecdsa.PublicKey] {
+	return EcdsaPublicKeyToJwkConverter{}
+}
+
+func (factory ConvertFactory) EcdsaPrivateKeyToJwkConverter() Converter[model.PrivateKeyJWK, *ecdsa.PrivateKey] {
+	return EcdsaPrivateKeyToJwkConverter{}
+}
+
+func (factory ConvertFactory) Ed25519PublicKeyToJwkConverter() Converter[model.PublicKeyJWK, ed25519.PublicKey] {
+	return Ed25519PublicKeyToJwkConverter{}
+}
+
+func (factory ConvertFactory) Ed25519PrivateKeyToJwkConverter() Converter[model.PrivateKeyJWK, ed25519.PrivateKey] {
+	return Ed25519PrivateKeyToJwkConverter{}
+}
@@ -0,0 +1,183 @@
+package converter
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+const MinRsaKeyBits = 2048
+
+var ErrKeyTooSmall = fmt.Errorf("rsa key must be at least %d bits", MinRsaKeyBits)
+
+// GenerateRsaKey generates an RSA key pair of the given size.
+func GenerateRsaKey(bits int) (*rsa.PrivateKey, error) {
+	if bits < MinRsaKeyBits {
+		return nil, ErrKeyTooSmall
+	}
+	return rsa.GenerateKey(rand.Reader, bits)
+}
+
+type PublicKeyToJwkConverter struct{}
+
+func (converter PublicKeyToJwkConverter) Convert(key *rsa.PublicKey) (model.PublicKeyJWK, error) {
+	if key.N.BitLen() < MinRsaKeyBits {
+		return model.PublicKeyJWK{}, ErrKeyTooSmall
+	}
+	jwk := model.PublicKeyJWK{
+		Alg: "RS256",
+		Kty: "RSA",
+		N:   encodeInt(key.N),
+		E:   encodeInt(big.NewInt(int64(key.E))),
+		Use: "sig",
+	}
+	return withThumbprintKid(jwk)
+}
+
+type PrivateKeyToJwkConverter struct{}
+
+func (converter PrivateKeyToJwkConverter) Convert(key *rsa.PrivateKey) (model.PrivateKeyJWK, error) {
+	if len(key.Primes) < 2 {
+		return model.PrivateKeyJWK{}, errors.New("rsa key is missing primes")
+	}
+	public, err := PublicKeyToJwkConverter{}.Convert(&key.PublicKey)
+	if err != nil {
+		return model.PrivateKeyJWK{}, err
+	}
+	key.Precompute()
+	var oth []model.OtherPrimeInfo
+	for i, crt := range key.Precomputed.CRTValues {
+		oth = append(oth, model.OtherPrimeInfo{
+			R: encodeInt(key.Primes[i+2]),
+			D: encodeInt(crt.Exp),
+			T: encodeInt(crt.Coeff),
+		})
+	}
+	return model.PrivateKeyJWK{
+		Kty: public.Kty,
+		Kid: public.Kid,
+		Alg: public.Alg,
+		N:   public.N,
+		E:   public.E,
+		D:   encodeInt(key.D),
+		P:   encodeInt(key.Primes[0]),
+		Q:   encodeInt(key.Primes[1]),
+		Dp:  encodeInt(key.Precomputed.Dp),
+		Dq:  encodeInt(key.Precomputed.Dq),
+		Qi:  encodeInt(key.Precomputed.Qinv),
+		Oth: oth,
+	}, nil
+}
+
+type EcdsaPublicKeyToJwkConverter struct{}
+
+func (converter EcdsaPublicKeyToJwkConverter) Convert(key *ecdsa.PublicKey) (model.PublicKeyJWK, error) {
+	crv, alg, err := curveParams(key.Curve)
+	if err != nil {
+		return model.PublicKeyJWK{}, err
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	jwk := model.PublicKeyJWK{
+		Alg: alg,
+		Kty: "EC",
+		Crv: crv,
+		X:   encodeFixed(key.X, size),
+		Y:   encodeFixed(key.Y, size),
+		Use: "sig",
+	}
+	return withThumbprintKid(jwk)
+}
+
+type EcdsaPrivateKeyToJwkConverter struct{}
+
+func (converter EcdsaPrivateKeyToJwkConverter) Convert(key *ecdsa.PrivateKey) (model.PrivateKeyJWK, error) {
+	public, err := EcdsaPublicKeyToJwkConverter{}.Convert(&key.PublicKey)
+	if err != nil {
+		return model.PrivateKeyJWK{}, err
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	return model.PrivateKeyJWK{
+		Kty: public.Kty,
+		Kid: public.Kid,
+		Alg: public.Alg,
+		Crv: public.Crv,
+		X:   public.X,
+		Y:   public.Y,
+		D:   encodeFixed(key.D, size),
+	}, nil
+}
+
+type Ed25519PublicKeyToJwkConverter struct{}
+
+func (converter Ed25519PublicKeyToJwkConverter) Convert(key ed25519.PublicKey) (model.PublicKeyJWK, error) {
+	if len(key) != ed25519.PublicKeySize {
+		return model.PublicKeyJWK{}, errors.New("invalid ed25519 public key")
+	}
+	jwk := model.PublicKeyJWK{
+		Alg: "EdDSA",
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(key),
+		Use: "sig",
+	}
+	return withThumbprintKid(jwk)
+}
+
+type Ed25519PrivateKeyToJwkConverter struct{}
+
+func (converter Ed25519PrivateKeyToJwkConverter) Convert(key ed25519.PrivateKey) (model.PrivateKeyJWK, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return model.PrivateKeyJWK{}, errors.New("invalid ed25519 private key")
+	}
+	public, err := Ed25519PublicKeyToJwkConverter{}.Convert(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		return model.PrivateKeyJWK{}, err
+	}
+	return model.PrivateKeyJWK{
+		Kty: public.Kty,
+		Kid: public.Kid,
+		Alg: public.Alg,
+		Crv: public.Crv,
+		X:   public.X,
+		D:   base64.RawURLEncoding.EncodeToString(key.Seed()),
+	}, nil
+}
+
+func withThumbprintKid(jwk model.PublicKeyJWK) (model.PublicKeyJWK, error) {
+	kid, err := jwk.Thumbprint()
+	if err != nil {
+		return model.PublicKeyJWK{}, err
+	}
+	jwk.Kid = kid
+	return jwk, nil
+}
+
+func curveParams(curve elliptic.Curve) (string, string, error) {
+	switch curve {
+	case elliptic.P256():
+		return "P-256", "ES256", nil
+	case elliptic.P384():
+		return "P-384", "ES384", nil
+	case elliptic.P521():
+		return "P-521", "ES512", nil
+	}
+	return "", "", errors.New("unsupported curve")
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// encodeFixed encodes i as a big-endian value padded to size bytes, as
+// RFC 7518 requires for EC coordinates.
+func encodeFixed(i *big.Int, size int) string {
+	return base64.RawURLEncoding.EncodeToString(i.FillBytes(make([]byte, size)))
+}
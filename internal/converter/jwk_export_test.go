@@ -0,0 +1,111 @@
+package converter
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"testing"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+func roundTripPrivateKey(t *testing.T, key *rsa.PrivateKey) *rsa.PrivateKey {
+	t.Helper()
+	jwk, err := PrivateKeyToJwkConverter{}.Convert(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(jwk.Oth) != len(key.Primes)-2 {
+		t.Fatalf("oth has %d entries for %d primes", len(jwk.Oth), len(key.Primes))
+	}
+	parsed, err := ParseRsaPrivateJwk(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+func checkSigns(t *testing.T, signer *rsa.PrivateKey, verifier *rsa.PublicKey) {
+	t.Helper()
+	digest := sha256.Sum256([]byte("payload"))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, signer, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = rsa.VerifyPKCS1v15(verifier, crypto.SHA256, digest[:], signature)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrivateKeyJwkCrtRoundTrip(t *testing.T) {
+	key, err := GenerateRsaKey(MinRsaKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := roundTripPrivateKey(t, key)
+	if !parsed.Equal(key) {
+		t.Fatal("round trip changed the key")
+	}
+	key.Precompute()
+	if parsed.Precomputed.Dp.Cmp(key.Precomputed.Dp) != 0 ||
+		parsed.Precomputed.Dq.Cmp(key.Precomputed.Dq) != 0 ||
+		parsed.Precomputed.Qinv.Cmp(key.Precomputed.Qinv) != 0 {
+		t.Error("round trip changed the CRT values")
+	}
+	checkSigns(t, parsed, &key.PublicKey)
+}
+
+func TestMultiPrimePrivateKeyJwkRoundTrip(t *testing.T) {
+	for _, primes := range []int{3, 4} {
+		//lint:ignore SA1019 multi-prime keys are generated to test oth support.
+		key, err := rsa.GenerateMultiPrimeKey(rand.Reader, primes, MinRsaKeyBits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed := roundTripPrivateKey(t, key)
+		if !parsed.Equal(key) || len(parsed.Primes) != primes {
+			t.Fatalf("%d primes: round trip changed the key", primes)
+		}
+		checkSigns(t, parsed, &key.PublicKey)
+	}
+}
+
+func TestMultiPrimePrivateKeyJwkRejectsBadOth(t *testing.T) {
+	//lint:ignore SA1019 multi-prime keys are generated to test oth support.
+	key, err := rsa.GenerateMultiPrimeKey(rand.Reader, 3, MinRsaKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := PrivateKeyToJwkConverter{}.Convert(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrongExponent := jwk
+	wrongExponent.Oth = append(wrongExponent.Oth[:0:0], jwk.Oth...)
+	wrongExponent.Oth[0].D = jwk.Dp
+	withoutCrt := jwk
+	withoutCrt.Dp, withoutCrt.Dq, withoutCrt.Qi = "", "", ""
+	missingPrime := jwk
+	missingPrime.Oth = nil
+
+	tests := []struct {
+		name   string
+		jwk    model.PrivateKeyJWK
+		member string
+	}{
+		{"wrong oth exponent", wrongExponent, "oth[0]"},
+		{"oth without crt", withoutCrt, "oth"},
+		{"missing prime", missingPrime, "p"},
+	}
+	for _, tt := range tests {
+		_, err := ParseRsaPrivateJwk(tt.jwk)
+		var jwkErr *JwkError
+		if !errors.As(err, &jwkErr) || jwkErr.Member != tt.member {
+			t.Errorf("%s: error = %v, want a JwkError for %s", tt.name, err, tt.member)
+		}
+	}
+}
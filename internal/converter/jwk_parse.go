@@ -0,0 +1,287 @@
+package converter
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+// JwkError reports which JWK member failed validation.
+type JwkError struct {
+	Member string
+	Reason string
+}
+
+func (e *JwkError) Error() string {
+	return fmt.Sprintf("invalid jwk %s: %s", e.Member, e.Reason)
+}
+
+var rsaAlgs = map[string]bool{
+	"RS256": true, "RS384": true, "RS512": true,
+	"PS256": true, "PS384": true, "PS512": true,
+}
+
+// ParseRsaPublicJwk strictly parses an RSA public JWK as specified by
+// RFC 7517 and RFC 7518 section 6.3.1.
+func ParseRsaPublicJwk(jwk model.PublicKeyJWK) (*rsa.PublicKey, error) {
+	err := checkRsaHeader(jwk.Kty, jwk.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if jwk.Use != "" && jwk.Use != "sig" {
+		return nil, &JwkError{Member: "use", Reason: fmt.Sprintf("unsupported use %q", jwk.Use)}
+	}
+	return parseRsaPublicMembers(jwk.N, jwk.E)
+}
+
+// ParseRsaPrivateJwk strictly parses an RSA private JWK as specified by
+// RFC 7518 section 6.3.2, checking the CRT members against the primes.
+func ParseRsaPrivateJwk(jwk model.PrivateKeyJWK) (*rsa.PrivateKey, error) {
+	err := checkRsaHeader(jwk.Kty, jwk.Alg)
+	if err != nil {
+		return nil, err
+	}
+	public, err := parseRsaPublicMembers(jwk.N, jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	d, err := decodeUint("d", jwk.D)
+	if err != nil {
+		return nil, err
+	}
+	p, err := decodeUint("p", jwk.P)
+	if err != nil {
+		return nil, err
+	}
+	q, err := decodeUint("q", jwk.Q)
+	if err != nil {
+		return nil, err
+	}
+	if d.Cmp(public.N) >= 0 {
+		return nil, &JwkError{Member: "d", Reason: "not less than modulus"}
+	}
+	primes := []*big.Int{p, q}
+	for i, other := range jwk.Oth {
+		r, err := decodeUint(fmt.Sprintf("oth[%d].r", i), other.R)
+		if err != nil {
+			return nil, err
+		}
+		primes = append(primes, r)
+	}
+	product := big.NewInt(1)
+	for _, prime := range primes {
+		product.Mul(product, prime)
+	}
+	if product.Cmp(public.N) != 0 {
+		return nil, &JwkError{Member: "p", Reason: "product of primes does not equal modulus"}
+	}
+	key := &rsa.PrivateKey{
+		PublicKey: *public,
+		D:         d,
+		Primes:    primes,
+	}
+	if len(jwk.Oth) > 0 && (jwk.Dp == "" || jwk.Dq == "" || jwk.Qi == "") {
+		return nil, &JwkError{Member: "oth", Reason: "requires dp, dq and qi"}
+	}
+	err = setCrtMembers(key, jwk.Dp, jwk.Dq, jwk.Qi)
+	if err != nil {
+		return nil, err
+	}
+	// With two primes Precompute keeps the CRT values set above; multi-prime
+	// keys have theirs computed and checked against oth below.
+	key.Precompute()
+	err = key.Validate()
+	if err != nil {
+		return nil, &JwkError{Member: "d", Reason: err.Error()}
+	}
+	err = checkOtherPrimes(key, jwk.Oth)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func checkRsaHeader(kty string, alg string) error {
+	if kty != "RSA" {
+		return &JwkError{Member: "kty", Reason: fmt.Sprintf("expected RSA, got %q", kty)}
+	}
+	if alg != "" && !rsaAlgs[alg] {
+		return &JwkError{Member: "alg", Reason: fmt.Sprintf("unsupported alg %q", alg)}
+	}
+	return nil
+}
+
+func parseRsaPublicMembers(encodedN string, encodedE string) (*rsa.PublicKey, error) {
+	n, err := decodeUint("n", encodedN)
+	if err != nil {
+		return nil, err
+	}
+	if n.BitLen() < MinRsaKeyBits {
+		return nil, &JwkError{Member: "n", Reason: fmt.Sprintf("modulus is %d bits, need at least %d", n.BitLen(), MinRsaKeyBits)}
+	}
+	if n.Bit(0) == 0 {
+		return nil, &JwkError{Member: "n", Reason: "modulus is even"}
+	}
+	e, err := decodeUint("e", encodedE)
+	if err != nil {
+		return nil, err
+	}
+	// Go only supports exponents that fit in 31 bits.
+	if e.BitLen() > 31 || e.Int64() < 3 || e.Bit(0) == 0 {
+		return nil, &JwkError{Member: "e", Reason: "exponent must be odd, at least 3 and at most 2^31-1"}
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+// setCrtMembers checks dp, dq and qi against d and the first two primes and
+// stores them as the key's precomputed values.
+func setCrtMembers(key *rsa.PrivateKey, encodedDp string, encodedDq string, encodedQi string) error {
+	if encodedDp == "" && encodedDq == "" && encodedQi == "" {
+		return nil
+	}
+	p, q := key.Primes[0], key.Primes[1]
+	one := big.NewInt(1)
+	expected := map[string]*big.Int{
+		"dp": new(big.Int).Mod(key.D, new(big.Int).Sub(p, one)),
+		"dq": new(big.Int).Mod(key.D, new(big.Int).Sub(q, one)),
+		"qi": new(big.Int).ModInverse(q, p),
+	}
+	values := make(map[string]*big.Int, len(expected))
+	for _, member := range []struct {
+		name    string
+		encoded string
+	}{{"dp", encodedDp}, {"dq", encodedDq}, {"qi", encodedQi}} {
+		value, err := decodeUint(member.name, member.encoded)
+		if err != nil {
+			return err
+		}
+		if expected[member.name] == nil || value.Cmp(expected[member.name]) != 0 {
+			return &JwkError{Member: member.name, Reason: "inconsistent with d, p and q"}
+		}
+		values[member.name] = value
+	}
+	key.Precomputed.Dp = values["dp"]
+	key.Precomputed.Dq = values["dq"]
+	key.Precomputed.Qinv = values["qi"]
+	return nil
+}
+
+func checkOtherPrimes(key *rsa.PrivateKey, oth []model.OtherPrimeInfo) error {
+	if len(key.Precomputed.CRTValues) != len(oth) {
+		return &JwkError{Member: "oth", Reason: "crt values missing"}
+	}
+	for i, other := range oth {
+		crt := key.Precomputed.CRTValues[i]
+		exp, err := decodeUint(fmt.Sprintf("oth[%d].d", i), other.D)
+		if err != nil {
+			return err
+		}
+		coeff, err := decodeUint(fmt.Sprintf("oth[%d].t", i), other.T)
+		if err != nil {
+			return err
+		}
+		if exp.Cmp(crt.Exp) != 0 || coeff.Cmp(crt.Coeff) != 0 {
+			return &JwkError{Member: fmt.Sprintf("oth[%d]", i), Reason: "inconsistent with d and the primes"}
+		}
+	}
+	return nil
+}
+
+// decodeUint decodes a Base64urlUInt: unpadded base64url of the minimal
+// big-endian representation of a positive integer.
+func decodeUint(member string, encoded string) (*big.Int, error) {
+	if encoded == "" {
+		return nil, &JwkError{Member: member, Reason: "missing"}
+	}
+	// The decoder skips line breaks even in strict mode.
+	if strings.ContainsAny(encoded, "\r\n") {
+		return nil, &JwkError{Member: member, Reason: "not canonical unpadded base64url"}
+	}
+	b, err := base64.RawURLEncoding.Strict().DecodeString(encoded)
+	if err != nil {
+		return nil, &JwkError{Member: member, Reason: "not canonical unpadded base64url"}
+	}
+	if b[0] == 0 {
+		return nil, &JwkError{Member: member, Reason: "has leading zero octets"}
+	}
+	return new(big.Int).SetBytes(b), nil
+}
+
+// ParsePublicJwk parses an RSA, EC or Ed25519 public JWK.
+func ParsePublicJwk(jwk model.PublicKeyJWK) (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		return ParseRsaPublicJwk(jwk)
+	case "EC":
+		return parseEcdsaPublicJwk(jwk)
+	case "OKP":
+		return parseEd25519PublicJwk(jwk)
+	}
+	return nil, &JwkError{Member: "kty", Reason: fmt.Sprintf("unsupported key type %q", jwk.Kty)}
+}
+
+var curves = map[string]elliptic.Curve{
+	"P-256": elliptic.P256(),
+	"P-384": elliptic.P384(),
+	"P-521": elliptic.P521(),
+}
+
+// parseEcdsaPublicJwk parses an EC public JWK as specified by RFC 7518
+// section 6.2.1, whose coordinates must have the full length of the curve.
+func parseEcdsaPublicJwk(jwk model.PublicKeyJWK) (*ecdsa.PublicKey, error) {
+	curve, ok := curves[jwk.Crv]
+	if !ok {
+		return nil, &JwkError{Member: "crv", Reason: fmt.Sprintf("unsupported curve %q", jwk.Crv)}
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	x, err := decodeFixed("x", jwk.X, size)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeFixed("y", jwk.Y, size)
+	if err != nil {
+		return nil, err
+	}
+	if !curve.IsOnCurve(x, y) {
+		return nil, &JwkError{Member: "x", Reason: "point is not on the curve"}
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func parseEd25519PublicJwk(jwk model.PublicKeyJWK) (ed25519.PublicKey, error) {
+	if jwk.Crv != "Ed25519" {
+		return nil, &JwkError{Member: "crv", Reason: fmt.Sprintf("unsupported curve %q", jwk.Crv)}
+	}
+	x, err := base64.RawURLEncoding.Strict().DecodeString(jwk.X)
+	if err != nil || strings.ContainsAny(jwk.X, "\r\n") {
+		return nil, &JwkError{Member: "x", Reason: "not canonical unpadded base64url"}
+	}
+	if len(x) != ed25519.PublicKeySize {
+		return nil, &JwkError{Member: "x", Reason: "wrong length"}
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+// decodeFixed decodes a fixed-length base64url member, which unlike a
+// Base64urlUInt keeps its leading zero octets.
+func decodeFixed(member string, encoded string, size int) (*big.Int, error) {
+	if strings.ContainsAny(encoded, "\r\n") {
+		return nil, &JwkError{Member: member, Reason: "not canonical unpadded base64url"}
+	}
+	b, err := base64.RawURLEncoding.Strict().DecodeString(encoded)
+	if err != nil {
+		return nil, &JwkError{Member: member, Reason: "not canonical unpadded base64url"}
+	}
+	if len(b) != size {
+		return nil, &JwkError{Member: member, Reason: fmt.Sprintf("must be %d octets", size)}
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,201 @@
+package converter
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+// rfc7517PublicKey is the RSA public key of RFC 7517 appendix A.1, whose RFC
+// 7638 thumbprint is given in RFC 7638 section 3.1.
+const rfc7517PublicKey = `{
+	"kty": "RSA",
+	"n": "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	"e": "AQAB",
+	"alg": "RS256",
+	"kid": "2011-04-29"
+}`
+
+const rfc7638Thumbprint = "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"
+
+// rfc7517PrivateKey is the RSA private key of RFC 7517 appendix A.2.
+const rfc7517PrivateKey = `{
+	"kty": "RSA",
+	"n": "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+	"e": "AQAB",
+	"d": "X4cTteJY_gn4FYPsXB8rdXix5vwsg1FLN5E3EaG6RJoVH-HLLKD9M7dx5oo7GURknchnrRweUkC7hT5fJLM0WbFAKNLWY2vv7B6NqXSzUvxT0_YSfqijwp3RTzlBaCxWp4doFk5N2o8Gy_nHNKroADIkJ46pRUohsXywbReAdYaMwFs9tv8d_cPVY3i07a3t8MN6TNwm0dSawm9v47UiCl3Sk5ZiG7xojPLu4sbg1U2jx4IBTNBznbJSzFHK66jT8bgkuqsk0GjskDJk19Z4qwjwbsnn4j2WBii3RL-Us2lGVkY8fkFzme1z0HbIkfz0Y6mqnOYtqc0X4jfcKoAC8Q",
+	"p": "83i-7IvMGXoMXCskv73TKr8637FiO7Z27zv8oj6pbWUQyLPQBQxtPVnwD20R-60eTDmD2ujnMt5PoqMrm8RfmNhVWDtjjMmCMjOpSXicFHj7XOuVIYQyqVWlWEh6dN36GVZYk93N8Bc9vY41xy8B9RzzOGVQzXvNEvn7O0nVbfs",
+	"q": "3dfOR9cuYq-0S-mkFLzgItgMEfFzB2q3hWehMuG0oCuqnb3vobLyumqjVZQO1dIrdwgTnCdpYzBcOfW5r370AFXjiWft_NGEiovonizhKpo9VVS78TzFgxkIdrecRezsZ-1kYd_s1qDbxtkDEgfAITAG9LUnADun4vIcb6yelxk",
+	"dp": "G4sPXkc6Ya9y8oJW9_ILj4xuppu0lzi_H7VTkS8xj5SdX3coE0oimYwxIi2emTAue0UOa5dpgFGyBJ4c8tQ2VF402XRugKDTP8akYhFo5tAA77Qe_NmtuYZc3C3m3I24G2GvR5sSDxUyAN2zq8Lfn9EUms6rY3Ob8YeiKkTiBj0",
+	"dq": "s9lAH9fggBsoFR8Oac2R_E2gw282rT2kGOAhvIllETE1efrA6huUUvMfBcMpn8lqeW6vzznYY5SSQF7pMdC_agI3nG8Ibp1BUb0JUiraRNqUfLhcQb_d9GF4Dh7e74WbRsobRonujTYN1xCaP6TO61jvWrX-L18txXw494Q_cgk",
+	"qi": "GyM_p6JrXySiz1toFgKbWV-JdI3jQ4ypu9rbMWx3rQJBfmt0FoYzgUIZEVFEcOqwemRN81zoDAaa-Bk0KWNGDjJHZDdDmFhW3AN7lI-puxk_mHZGJ11rxyR8O55XLSe3SPmRfKwZI6yU24ZxvQKFYItdldUKGzO6Ia6zTKhAVRU",
+	"alg": "RS256",
+	"kid": "2011-04-29"
+}`
+
+func rfcPublicJwk(t testing.TB) model.PublicKeyJWK {
+	t.Helper()
+	var jwk model.PublicKeyJWK
+	err := json.Unmarshal([]byte(rfc7517PublicKey), &jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwk
+}
+
+func rfcPrivateJwk(t testing.TB) model.PrivateKeyJWK {
+	t.Helper()
+	var jwk model.PrivateKeyJWK
+	err := json.Unmarshal([]byte(rfc7517PrivateKey), &jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwk
+}
+
+func TestParseRsaPublicJwkRfc7517(t *testing.T) {
+	jwk := rfcPublicJwk(t)
+	key, err := ParseRsaPublicJwk(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.E != 65537 || key.N.BitLen() != 2048 {
+		t.Errorf("e = %d, modulus = %d bits", key.E, key.N.BitLen())
+	}
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumbprint != rfc7638Thumbprint {
+		t.Errorf("thumbprint = %s, want %s", thumbprint, rfc7638Thumbprint)
+	}
+	exported, err := PublicKeyToJwkConverter{}.Convert(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.N != jwk.N || exported.E != jwk.E || exported.Kid != rfc7638Thumbprint {
+		t.Error("exporting the parsed key does not give back the RFC members")
+	}
+}
+
+func TestParseRsaPrivateJwkRfc7517(t *testing.T) {
+	jwk := rfcPrivateJwk(t)
+	key, err := ParseRsaPrivateJwk(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	public, err := ParseRsaPublicJwk(rfcPublicJwk(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.PublicKey.Equal(public) {
+		t.Error("private key does not match the RFC public key")
+	}
+	exported, err := PrivateKeyToJwkConverter{}.Convert(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported.D != jwk.D || exported.P != jwk.P || exported.Q != jwk.Q ||
+		exported.Dp != jwk.Dp || exported.Dq != jwk.Dq || exported.Qi != jwk.Qi {
+		t.Error("exporting the parsed key does not give back the RFC members")
+	}
+}
+
+func TestParseRsaPublicJwkRejects(t *testing.T) {
+	small := encodeInt(new(big.Int).Lsh(big.NewInt(1), 1023))
+	tests := []struct {
+		name   string
+		edit   func(jwk *model.PublicKeyJWK)
+		member string
+	}{
+		{"wrong kty", func(jwk *model.PublicKeyJWK) { jwk.Kty = "EC" }, "kty"},
+		{"hmac alg", func(jwk *model.PublicKeyJWK) { jwk.Alg = "HS256" }, "alg"},
+		{"encryption use", func(jwk *model.PublicKeyJWK) { jwk.Use = "enc" }, "use"},
+		{"missing n", func(jwk *model.PublicKeyJWK) { jwk.N = "" }, "n"},
+		{"padded n", func(jwk *model.PublicKeyJWK) { jwk.N += "=" }, "n"},
+		{"standard base64 n", func(jwk *model.PublicKeyJWK) { jwk.N = strings.ReplaceAll(jwk.N, "_", "/") }, "n"},
+		{"line break in n", func(jwk *model.PublicKeyJWK) { jwk.N = jwk.N[:10] + "\n" + jwk.N[10:] }, "n"},
+		{"leading zero octet", func(jwk *model.PublicKeyJWK) { jwk.E = "AAEAAQ" }, "e"},
+		{"small modulus", func(jwk *model.PublicKeyJWK) { jwk.N = small }, "n"},
+		{"even modulus", func(jwk *model.PublicKeyJWK) {
+			n, _ := new(big.Int).SetString("1"+strings.Repeat("0", 620), 10)
+			jwk.N = encodeInt(n)
+		}, "n"},
+		{"even exponent", func(jwk *model.PublicKeyJWK) { jwk.E = encodeInt(big.NewInt(65536)) }, "e"},
+		{"exponent one", func(jwk *model.PublicKeyJWK) { jwk.E = "AQ" }, "e"},
+		{"huge exponent", func(jwk *model.PublicKeyJWK) { jwk.E = encodeInt(new(big.Int).Lsh(big.NewInt(1), 40)) }, "e"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwk := rfcPublicJwk(t)
+			tt.edit(&jwk)
+			_, err := ParseRsaPublicJwk(jwk)
+			var jwkErr *JwkError
+			if !errors.As(err, &jwkErr) || jwkErr.Member != tt.member {
+				t.Fatalf("error = %v, want a JwkError for %s", err, tt.member)
+			}
+		})
+	}
+}
+
+func TestParseRsaPrivateJwkRejects(t *testing.T) {
+	tests := []struct {
+		name   string
+		edit   func(jwk *model.PrivateKeyJWK)
+		member string
+	}{
+		{"missing d", func(jwk *model.PrivateKeyJWK) { jwk.D = "" }, "d"},
+		{"missing q", func(jwk *model.PrivateKeyJWK) { jwk.Q = "" }, "q"},
+		{"primes do not multiply to n", func(jwk *model.PrivateKeyJWK) { jwk.Q = jwk.P }, "p"},
+		{"d not less than n", func(jwk *model.PrivateKeyJWK) { jwk.D = jwk.N }, "d"},
+		{"wrong d", func(jwk *model.PrivateKeyJWK) {
+			jwk.D = jwk.Dp
+			jwk.Dp, jwk.Dq, jwk.Qi = "", "", ""
+		}, "d"},
+		{"wrong dp", func(jwk *model.PrivateKeyJWK) { jwk.Dp = jwk.Dq }, "dp"},
+		{"wrong qi", func(jwk *model.PrivateKeyJWK) { jwk.Qi = jwk.Dp }, "qi"},
+		{"partial crt", func(jwk *model.PrivateKeyJWK) { jwk.Dq = "" }, "dq"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwk := rfcPrivateJwk(t)
+			tt.edit(&jwk)
+			_, err := ParseRsaPrivateJwk(jwk)
+			var jwkErr *JwkError
+			if !errors.As(err, &jwkErr) || jwkErr.Member != tt.member {
+				t.Fatalf("error = %v, want a JwkError for %s", err, tt.member)
+			}
+		})
+	}
+}
+
+// FuzzParseRsaPublicJwk checks that the parser never panics and only accepts
+// canonical encodings, which export back unchanged.
+func FuzzParseRsaPublicJwk(f *testing.F) {
+	jwk := rfcPublicJwk(f)
+	f.Add(jwk.N, jwk.E, jwk.Alg)
+	f.Add(jwk.N+"=", jwk.E, "")
+	f.Add("AA"+jwk.N, "AAEAAQ", "PS256")
+	f.Add(jwk.N[:200], "Aw", "RS512")
+	f.Add("", "", "none")
+	f.Fuzz(func(t *testing.T, n string, e string, alg string) {
+		key, err := ParseRsaPublicJwk(model.PublicKeyJWK{Kty: "RSA", N: n, E: e, Alg: alg})
+		if err != nil {
+			var jwkErr *JwkError
+			if !errors.As(err, &jwkErr) {
+				t.Fatalf("error %v is not a JwkError", err)
+			}
+			return
+		}
+		if encodeInt(key.N) != n || encodeInt(big.NewInt(int64(key.E))) != e {
+			t.Fatalf("accepted non-canonical n %q or e %q", n, e)
+		}
+		if key.N.BitLen() < MinRsaKeyBits || key.E < 3 || key.E%2 == 0 {
+			t.Fatalf("accepted weak key: %d bits, e = %d", key.N.BitLen(), key.E)
+		}
+	})
+}
@@ -0,0 +1,228 @@
+package converter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	ErrInvalidPemBlock       = errors.New("invalid pem block")
+	ErrNotRsaKey             = errors.New("pem block does not contain an rsa key")
+	ErrPasswordRequired      = errors.New("encrypted private key requires a password")
+	ErrIncorrectPassword     = errors.New("incorrect password for encrypted private key")
+	ErrUnsupportedEncryption = errors.New("unsupported private key encryption")
+	ErrMalformedEncryption   = errors.New("malformed encrypted private key")
+)
+
+// MaxPbkdf2Iterations bounds the work a crafted key file can demand. OpenSSL
+// uses 2048 iterations by default.
+const MaxPbkdf2Iterations = 10_000_000
+
+type UnsupportedBlockTypeError struct {
+	Type string
+}
+
+func (e *UnsupportedBlockTypeError) Error() string {
+	return fmt.Sprintf("unsupported pem block type %q", e.Type)
+}
+
+// parseRsaPublicKeyPem accepts PKCS#1 and PKIX public keys and X.509
+// certificates.
+func parseRsaPublicKeyPem(data []byte) (*rsa.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPemBlock
+	}
+	var key any
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		cert, err = x509.ParseCertificate(block.Bytes)
+		if err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, &UnsupportedBlockTypeError{Type: block.Type}
+	}
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, ErrNotRsaKey
+	}
+	return rsaKey, nil
+}
+
+// parseRsaPrivateKeyPem accepts PKCS#1 and PKCS#8 private keys. Encrypted
+// PKCS#8 keys are decrypted with password.
+func parseRsaPrivateKeyPem(data []byte, password []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, ErrInvalidPemBlock
+	}
+	der := block.Bytes
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		if _, ok := block.Headers["DEK-Info"]; ok {
+			return nil, ErrUnsupportedEncryption
+		}
+		return x509.ParsePKCS1PrivateKey(der)
+	case "PRIVATE KEY":
+	case "ENCRYPTED PRIVATE KEY":
+		if len(password) == 0 {
+			return nil, ErrPasswordRequired
+		}
+		var err error
+		der, err = decryptPkcs8(der, password)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, &UnsupportedBlockTypeError{Type: block.Type}
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		if block.Type == "ENCRYPTED PRIVATE KEY" {
+			return nil, ErrIncorrectPassword
+		}
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, ErrNotRsaKey
+	}
+	return rsaKey, nil
+}
+
+var (
+	oidPbes2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPbkdf2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHmacWithSha1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHmacWithSha256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHmacWithSha512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAes128Cbc      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAes192Cbc      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAes256Cbc      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     algorithmIdentifier
+	EncryptedData []byte
+}
+
+type algorithmIdentifier struct {
+	Algorithm  asn1.ObjectIdentifier
+	Parameters asn1.RawValue `asn1:"optional"`
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc algorithmIdentifier
+	EncryptionScheme  algorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                 `asn1:"optional"`
+	Prf            algorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPkcs8 decrypts a PBES2 (PBKDF2 + AES-CBC) EncryptedPrivateKeyInfo,
+// which is what OpenSSL produces by default.
+func decryptPkcs8(der []byte, password []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	_, err := asn1.Unmarshal(der, &info)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPbes2) {
+		return nil, ErrUnsupportedEncryption
+	}
+	var params pbes2Params
+	_, err = asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params)
+	if err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPbkdf2) {
+		return nil, ErrUnsupportedEncryption
+	}
+	var kdf pbkdf2Params
+	_, err = asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf)
+	if err != nil {
+		return nil, err
+	}
+	if kdf.IterationCount < 1 || kdf.IterationCount > MaxPbkdf2Iterations {
+		return nil, fmt.Errorf("%w: pbkdf2 iteration count %d", ErrMalformedEncryption, kdf.IterationCount)
+	}
+	var prf func() hash.Hash
+	switch {
+	case len(kdf.Prf.Algorithm) == 0, kdf.Prf.Algorithm.Equal(oidHmacWithSha1):
+		prf = sha1.New
+	case kdf.Prf.Algorithm.Equal(oidHmacWithSha256):
+		prf = sha256.New
+	case kdf.Prf.Algorithm.Equal(oidHmacWithSha512):
+		prf = sha512.New
+	default:
+		return nil, ErrUnsupportedEncryption
+	}
+	var keyLen int
+	switch {
+	case params.EncryptionScheme.Algorithm.Equal(oidAes128Cbc):
+		keyLen = 16
+	case params.EncryptionScheme.Algorithm.Equal(oidAes192Cbc):
+		keyLen = 24
+	case params.EncryptionScheme.Algorithm.Equal(oidAes256Cbc):
+		keyLen = 32
+	default:
+		return nil, ErrUnsupportedEncryption
+	}
+	if kdf.KeyLength != 0 && kdf.KeyLength != keyLen {
+		return nil, fmt.Errorf("%w: key length %d", ErrMalformedEncryption, kdf.KeyLength)
+	}
+	var iv []byte
+	_, err = asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("%w: iv length %d", ErrMalformedEncryption, len(iv))
+	}
+	if len(info.EncryptedData) == 0 || len(info.EncryptedData)%aes.BlockSize != 0 {
+		return nil, fmt.Errorf("%w: ciphertext is not whole blocks", ErrMalformedEncryption)
+	}
+	key := pbkdf2.Key(password, kdf.Salt, kdf.IterationCount, keyLen, prf)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plain, info.EncryptedData)
+	padding := int(plain[len(plain)-1])
+	if padding == 0 || padding > aes.BlockSize {
+		return nil, ErrIncorrectPassword
+	}
+	for _, b := range plain[len(plain)-padding:] {
+		if int(b) != padding {
+			return nil, ErrIncorrectPassword
+		}
+	}
+	return plain[:len(plain)-padding], nil
+}
@@ -0,0 +1,158 @@
+package converter
+
+import (
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// The fixtures in testdata were generated with OpenSSL 3, for example
+//
+//	openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:2048 -out rsa_pkcs8.pem
+//	openssl pkcs8 -topk8 -in rsa_pkcs8.pem -v2 aes-256-cbc -v2prf hmacWithSHA256 -passout pass:s3cret -out rsa_aes256_sha256.pem
+const fixturePassword = "s3cret"
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func fixtureKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := parseRsaPrivateKeyPem(readFixture(t, "rsa_pkcs8.pem"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func TestParseRsaPrivateKeyPem(t *testing.T) {
+	want := fixtureKey(t)
+	tests := []struct {
+		file     string
+		password string
+		wantErr  error
+	}{
+		{file: "rsa_pkcs1.pem"},
+		{file: "rsa_pkcs8.pem"},
+		{file: "rsa_aes256_sha256.pem", password: fixturePassword},
+		{file: "rsa_aes128_sha1.pem", password: fixturePassword},
+		{file: "rsa_aes192_sha512.pem", password: fixturePassword},
+		{file: "rsa_aes256_sha256.pem", wantErr: ErrPasswordRequired},
+		{file: "rsa_aes256_sha256.pem", password: "wrong", wantErr: ErrIncorrectPassword},
+		{file: "rsa_des3.pem", password: fixturePassword, wantErr: ErrUnsupportedEncryption},
+		{file: "ec_pkcs8.pem", wantErr: ErrNotRsaKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file+"/"+tt.password, func(t *testing.T) {
+			key, err := parseRsaPrivateKeyPem(readFixture(t, tt.file), []byte(tt.password))
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !key.Equal(want) {
+				t.Error("decoded key differs from the unencrypted fixture")
+			}
+		})
+	}
+}
+
+func TestParseRsaPublicKeyPem(t *testing.T) {
+	want := &fixtureKey(t).PublicKey
+	for _, file := range []string{"rsa_pkcs1_public.pem", "rsa_pkix_public.pem", "rsa_cert.pem"} {
+		t.Run(file, func(t *testing.T) {
+			key, err := parseRsaPublicKeyPem(readFixture(t, file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !key.Equal(want) {
+				t.Error("decoded key differs from the fixture")
+			}
+		})
+	}
+	var blockErr *UnsupportedBlockTypeError
+	_, err := parseRsaPublicKeyPem(readFixture(t, "rsa_pkcs8.pem"))
+	if !errors.As(err, &blockErr) {
+		t.Errorf("private key block error = %v, want UnsupportedBlockTypeError", err)
+	}
+}
+
+// tamperEncryptedKey re-encodes the encrypted fixture after edit changes its
+// PBES2 parameters.
+func tamperEncryptedKey(t *testing.T, edit func(kdf *pbkdf2Params, iv *[]byte)) []byte {
+	t.Helper()
+	block, _ := pem.Decode(readFixture(t, "rsa_aes256_sha256.pem"))
+	var info encryptedPrivateKeyInfo
+	var params pbes2Params
+	var kdf pbkdf2Params
+	var iv []byte
+	mustUnmarshal(t, block.Bytes, &info)
+	mustUnmarshal(t, info.Algorithm.Parameters.FullBytes, &params)
+	mustUnmarshal(t, params.KeyDerivationFunc.Parameters.FullBytes, &kdf)
+	mustUnmarshal(t, params.EncryptionScheme.Parameters.FullBytes, &iv)
+	edit(&kdf, &iv)
+	params.KeyDerivationFunc.Parameters = mustRaw(t, kdf)
+	params.EncryptionScheme.Parameters = mustRaw(t, iv)
+	info.Algorithm.Parameters = mustRaw(t, params)
+	der, err := asn1.Marshal(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der})
+}
+
+func mustUnmarshal(t *testing.T, der []byte, out any) {
+	t.Helper()
+	_, err := asn1.Unmarshal(der, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustRaw(t *testing.T, value any) asn1.RawValue {
+	t.Helper()
+	der, err := asn1.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return asn1.RawValue{FullBytes: der}
+}
+
+func TestDecryptPkcs8RejectsMalformedParameters(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(kdf *pbkdf2Params, iv *[]byte)
+	}{
+		{"huge iteration count", func(kdf *pbkdf2Params, iv *[]byte) { kdf.IterationCount = 1 << 30 }},
+		{"zero iteration count", func(kdf *pbkdf2Params, iv *[]byte) { kdf.IterationCount = 0 }},
+		{"short iv", func(kdf *pbkdf2Params, iv *[]byte) { *iv = (*iv)[:8] }},
+		{"key length mismatch", func(kdf *pbkdf2Params, iv *[]byte) { kdf.KeyLength = 16 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tamperEncryptedKey(t, tt.edit)
+			start := time.Now()
+			_, err := parseRsaPrivateKeyPem(data, []byte(fixturePassword))
+			if !errors.Is(err, ErrMalformedEncryption) {
+				t.Fatalf("error = %v, want ErrMalformedEncryption", err)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("rejecting took %v", elapsed)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package dpop
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnsupportedKey = errors.New("unsupported dpop key type")
+
+// NewProof creates a proof signed with key for a request with method to url.
+// accessToken is the token sent with the request, or empty for the token
+// endpoint.
+func NewProof(key crypto.Signer, method string, url string, accessToken string) (string, error) {
+	signingMethod, jwk, err := signingParams(key)
+	if err != nil {
+		return "", err
+	}
+	jti := make([]byte, 16)
+	_, err = rand.Read(jti)
+	if err != nil {
+		return "", err
+	}
+	claims := proofClaims{
+		Htm: method,
+		Htu: url,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:       base64.RawURLEncoding.EncodeToString(jti),
+			IssuedAt: jwt.NewNumericDate(time.Now()),
+		},
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims.Ath = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	token := jwt.NewWithClaims(signingMethod, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = jwk
+	return token.SignedString(key)
+}
+
+// Thumbprint returns the jkt of the public key of key, for the cnf claim of
+// tokens bound to it.
+func Thumbprint(key crypto.Signer) (string, error) {
+	_, jwk, err := signingParams(key)
+	if err != nil {
+		return "", err
+	}
+	return jwk.Thumbprint()
+}
+
+func signingParams(key crypto.Signer) (jwt.SigningMethod, model.PublicKeyJWK, error) {
+	factory := converter.ConvertFactory{}
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		jwk, err := factory.PublicKeyToJwkConverter().Convert(&key.PublicKey)
+		return jwt.SigningMethodRS256, jwk, err
+	case *ecdsa.PrivateKey:
+		jwk, err := factory.EcdsaPublicKeyToJwkConverter().Convert(&key.PublicKey)
+		if err != nil {
+			return nil, model.PublicKeyJWK{}, err
+		}
+		return jwt.GetSigningMethod(jwk.Alg), jwk, nil
+	case ed25519.PrivateKey:
+		jwk, err := factory.Ed25519PublicKeyToJwkConverter().Convert(key.Public().(ed25519.PublicKey))
+		return jwt.SigningMethodEdDSA, jwk, err
+	}
+	return nil, model.PublicKeyJWK{}, ErrUnsupportedKey
+}
@@ -0,0 +1,38 @@
+package dpop
+
+import (
+	"net/url"
+	"strings"
+)
+
+// sameUrl compares htu values as RFC 9449 section 4.3 requires: without query
+// and fragment, after syntax-based normalization.
+func sameUrl(a string, b string) bool {
+	normalizedA, ok := normalizeUrl(a)
+	if !ok {
+		return false
+	}
+	normalizedB, ok := normalizeUrl(b)
+	return ok && normalizedA == normalizedB
+}
+
+func normalizeUrl(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return "", false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if (scheme == "https" && port == "443") || (scheme == "http" && port == "80") {
+		port = ""
+	}
+	if port != "" {
+		host += ":" + port
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return scheme + "://" + host + path, true
+}
@@ -0,0 +1,154 @@
+package dpop
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/replay"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Header is the request header carrying the proof.
+const Header = "DPoP"
+
+// Algs are the proof signing algorithms accepted, as advertised in the
+// algs parameter of DPoP challenges.
+var Algs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+var ErrInvalidProof = errors.New("invalid dpop proof")
+
+// ErrNoBaseUrl is returned when a request is verified without the external
+// base URL of the service. Deriving it from the Host header would let a
+// client choose the htu its proof is checked against.
+var ErrNoBaseUrl = errors.New("dpop: no base url configured")
+
+// proofClaims are the claims of an RFC 9449 section 4.2 proof.
+type proofClaims struct {
+	Htm string `json:"htm"`
+	Htu string `json:"htu"`
+	Ath string `json:"ath,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Verifier verifies DPoP proofs. A proof is accepted if it was issued within
+// window of now and its jti has not been seen within that time.
+type Verifier struct {
+	window time.Duration
+	replay replay.Cache
+	now    func() time.Time
+}
+
+func NewVerifier(window time.Duration, replayCache replay.Cache) *Verifier {
+	return &Verifier{
+		window: window,
+		replay: replayCache,
+		now:    time.Now,
+	}
+}
+
+// Verify checks proof for a request with method to url and returns the
+// thumbprint of the proof key. accessToken is the token the proof is sent
+// with, or empty at the token endpoint.
+func (v *Verifier) Verify(ctx context.Context, proof string, method string, url string, accessToken string) (string, error) {
+	var jwk model.PublicKeyJWK
+	claims := &proofClaims{}
+	parser := jwt.NewParser(jwt.WithValidMethods(Algs))
+	_, err := parser.ParseWithClaims(proof, claims, func(t *jwt.Token) (any, error) {
+		if t.Header["typ"] != "dpop+jwt" {
+			return nil, errors.New("typ must be dpop+jwt")
+		}
+		key, err := headerJwk(t.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		jwk = key
+		return converter.ParsePublicJwk(key)
+	})
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	if claims.Htm != method {
+		return "", fmt.Errorf("%w: htm does not match the request method", ErrInvalidProof)
+	}
+	if !sameUrl(claims.Htu, url) {
+		return "", fmt.Errorf("%w: htu does not match the request url", ErrInvalidProof)
+	}
+	if claims.IssuedAt == nil {
+		return "", fmt.Errorf("%w: missing iat", ErrInvalidProof)
+	}
+	now := v.now()
+	iat := claims.IssuedAt.Time
+	if iat.Before(now.Add(-v.window)) || iat.After(now.Add(v.window)) {
+		return "", fmt.Errorf("%w: iat is outside the accepted window", ErrInvalidProof)
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		ath := base64.RawURLEncoding.EncodeToString(sum[:])
+		if subtle.ConstantTimeCompare([]byte(claims.Ath), []byte(ath)) != 1 {
+			return "", fmt.Errorf("%w: ath does not match the access token", ErrInvalidProof)
+		}
+	}
+	if claims.ID == "" {
+		return "", fmt.Errorf("%w: missing jti", ErrInvalidProof)
+	}
+	jkt, err := jwk.Thumbprint()
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	err = v.replay.Use(ctx, jkt+":"+claims.ID, iat.Add(v.window))
+	if errors.Is(err, replay.ErrReplayed) {
+		return "", fmt.Errorf("%w: jti was already used", ErrInvalidProof)
+	}
+	if err != nil {
+		return "", err
+	}
+	return jkt, nil
+}
+
+// VerifyRequest verifies the single DPoP header of r, sent to baseUrl plus
+// the request path. baseUrl is the external scheme and host of the service
+// and is required.
+func (v *Verifier) VerifyRequest(r *http.Request, baseUrl string, accessToken string) (string, error) {
+	if baseUrl == "" {
+		return "", ErrNoBaseUrl
+	}
+	proofs := r.Header.Values(Header)
+	if len(proofs) != 1 {
+		return "", fmt.Errorf("%w: expected exactly one %s header", ErrInvalidProof, Header)
+	}
+	return v.Verify(r.Context(), proofs[0], r.Method, RequestUrl(r, baseUrl), accessToken)
+}
+
+// RequestUrl returns the htu of r: baseUrl with the request path.
+func RequestUrl(r *http.Request, baseUrl string) string {
+	return strings.TrimSuffix(baseUrl, "/") + r.URL.Path
+}
+
+func headerJwk(value any) (model.PublicKeyJWK, error) {
+	members, ok := value.(map[string]any)
+	if !ok {
+		return model.PublicKeyJWK{}, errors.New("missing jwk header")
+	}
+	for _, private := range []string{"d", "p", "q", "dp", "dq", "qi", "k"} {
+		if _, ok := members[private]; ok {
+			return model.PublicKeyJWK{}, errors.New("jwk header contains private key members")
+		}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return model.PublicKeyJWK{}, err
+	}
+	var jwk model.PublicKeyJWK
+	err = json.Unmarshal(data, &jwk)
+	return jwk, err
+}
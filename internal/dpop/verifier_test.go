@@ -0,0 +1,189 @@
+package dpop
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/replay"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestVerifyRequestRequiresBaseUrl(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The client points the Host header at the htu its proof was made for.
+	proof, err := NewProof(key, http.MethodGet, "http://attacker.example/orders", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	r.Host = "attacker.example"
+	r.Header.Set(Header, proof)
+
+	verifier := NewVerifier(time.Minute, replay.NewMemoryCache())
+	_, err = verifier.VerifyRequest(r, "", "")
+	if !errors.Is(err, ErrNoBaseUrl) {
+		t.Fatalf("error = %v, want ErrNoBaseUrl", err)
+	}
+	_, err = verifier.VerifyRequest(r, "https://api.example", "")
+	if !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("error = %v, want ErrInvalidProof for the wrong htu", err)
+	}
+}
+
+const testUrl = "https://api.example/orders"
+
+// signProof signs claims with key as a proof, after edit changes its header.
+func signProof(t *testing.T, key *ecdsa.PrivateKey, claims proofClaims, edit func(header map[string]any)) string {
+	t.Helper()
+	method, jwk, err := signingParams(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["typ"] = "dpop+jwt"
+	token.Header["jwk"] = jwk
+	if edit != nil {
+		edit(token.Header)
+	}
+	proof, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+func TestVerify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	sum := sha256.Sum256([]byte("access-token"))
+	ath := base64.RawURLEncoding.EncodeToString(sum[:])
+	valid := func() proofClaims {
+		return proofClaims{
+			Htm: http.MethodGet,
+			Htu: testUrl,
+			Ath: ath,
+			RegisteredClaims: jwt.RegisteredClaims{
+				ID:       "jti-1",
+				IssuedAt: jwt.NewNumericDate(now),
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		claims  func(c *proofClaims)
+		header  func(header map[string]any)
+		proof   func(proof string) string
+		wantErr bool
+	}{
+		{name: "valid"},
+		{name: "tampered signature", proof: func(proof string) string {
+			other := signProof(t, other, valid(), nil)
+			return proof[:strings.LastIndex(proof, ".")] + other[strings.LastIndex(other, "."):]
+		}, wantErr: true},
+		{name: "missing typ", header: func(h map[string]any) { delete(h, "typ") }, wantErr: true},
+		{name: "jwt typ", header: func(h map[string]any) { h["typ"] = "JWT" }, wantErr: true},
+		{name: "unsigned", proof: func(proof string) string {
+			token := jwt.NewWithClaims(jwt.SigningMethodNone, valid())
+			_, jwk, _ := signingParams(key)
+			token.Header["typ"] = "dpop+jwt"
+			token.Header["jwk"] = jwk
+			unsigned, _ := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return unsigned
+		}, wantErr: true},
+		{name: "missing jwk", header: func(h map[string]any) { delete(h, "jwk") }, wantErr: true},
+		{name: "private jwk", header: func(h map[string]any) {
+			_, jwk, _ := signingParams(key)
+			h["jwk"] = map[string]any{"kty": jwk.Kty, "crv": jwk.Crv, "x": jwk.X, "y": jwk.Y, "d": "secret"}
+		}, wantErr: true},
+		{name: "other htm", claims: func(c *proofClaims) { c.Htm = http.MethodPost }, wantErr: true},
+		{name: "other htu", claims: func(c *proofClaims) { c.Htu = "https://api.example/billing" }, wantErr: true},
+		{name: "htu with query", claims: func(c *proofClaims) { c.Htu = testUrl + "?page=2" }},
+		{name: "missing iat", claims: func(c *proofClaims) { c.IssuedAt = nil }, wantErr: true},
+		{name: "iat within window", claims: func(c *proofClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(-30 * time.Second)) }},
+		{name: "iat too old", claims: func(c *proofClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(-2 * time.Minute)) }, wantErr: true},
+		{name: "iat in the future", claims: func(c *proofClaims) { c.IssuedAt = jwt.NewNumericDate(now.Add(2 * time.Minute)) }, wantErr: true},
+		{name: "missing ath", claims: func(c *proofClaims) { c.Ath = "" }, wantErr: true},
+		{name: "other ath", claims: func(c *proofClaims) { c.Ath = "other" }, wantErr: true},
+		{name: "missing jti", claims: func(c *proofClaims) { c.ID = "" }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			if tt.claims != nil {
+				tt.claims(&claims)
+			}
+			proof := signProof(t, key, claims, tt.header)
+			if tt.proof != nil {
+				proof = tt.proof(proof)
+			}
+			verifier := NewVerifier(time.Minute, replay.NewMemoryCache())
+			verifier.now = func() time.Time { return now }
+			jkt, err := verifier.Verify(context.Background(), proof, http.MethodGet, testUrl, "access-token")
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidProof) {
+					t.Fatalf("error = %v, want ErrInvalidProof", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := Thumbprint(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if jkt != want {
+				t.Errorf("jkt = %s, want %s", jkt, want)
+			}
+		})
+	}
+}
+
+func TestVerifyRejectsReplayedJti(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier := NewVerifier(time.Minute, replay.NewMemoryCache())
+	proof, err := NewProof(key, http.MethodGet, testUrl, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = verifier.Verify(context.Background(), proof, http.MethodGet, testUrl, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = verifier.Verify(context.Background(), proof, http.MethodGet, testUrl, "")
+	if !errors.Is(err, ErrInvalidProof) {
+		t.Fatalf("error = %v, want ErrInvalidProof for a replayed proof", err)
+	}
+	// Another proof of the same key is still accepted.
+	proof, err = NewProof(key, http.MethodGet, testUrl, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = verifier.Verify(context.Background(), proof, http.MethodGet, testUrl, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
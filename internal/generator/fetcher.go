@@ -1,11 +1,13 @@
 package generator
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
 )
 
 func NewPrivateKeyFetcher(
@@ -39,6 +41,9 @@ func (p *PrivateKeyFetcher) Start() {
 			select {
 			case <-p.ticker.C:
 				jwk, err := p.fetch()
+				if errors.Is(err, storage.ErrNotModified) {
+					continue
+				}
 				if err != nil {
 					fmt.Println("error: ", err)
 					continue
@@ -2,15 +2,21 @@ package generator
 
 import (
 	"context"
+	"crypto/rand"
 	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
 	"time"
 
 	backgroundfetcher "github.com/CalvinCYCheung/go_token_validator/internal/background_fetcher"
 	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/keyevent"
 	"github.com/CalvinCYCheung/go_token_validator/internal/model"
 	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -22,72 +28,268 @@ type TokenGenerator interface {
 	Generate(token string) (string, error)
 }
 
+// TokenTTL is the lifetime of generated tokens.
+const TokenTTL = 15 * time.Minute
+
+// DefaultPropagationDelay is how long a key is published before the generator
+// signs with it, unless configured otherwise.
+const DefaultPropagationDelay = 15 * time.Minute
+
+// activeKeyPollInterval is how often NewTokenGenerator checks whether a
+// pending key has become active.
+const activeKeyPollInterval = 5 * time.Second
+
+// DefaultActiveKeyWait is how long NewTokenGenerator waits for a pending key
+// to become active. Keys are pending for DefaultPropagationDelay at most.
+const DefaultActiveKeyWait = DefaultPropagationDelay + activeKeyPollInterval
+
+// ErrNoActiveKey is returned by a fetch function while every published key is
+// still pending.
+var ErrNoActiveKey = errors.New("jwks has no active key yet")
+
 type TokenGeneratorImpl struct {
-	mu         sync.RWMutex
-	privateKey *rsa.PrivateKey
-	converter  converter.Converter[*rsa.PrivateKey, model.PrivateKeyJWK]
-	kid        string
-	fetcher    backgroundfetcher.BackgroundFetcher
+	mu           sync.RWMutex
+	privateKey   *rsa.PrivateKey
+	converter    converter.Converter[*rsa.PrivateKey, model.PrivateKeyJWK]
+	kid          string
+	signingKey   model.PublicKeyJWK
+	retiringKeys []retiringKey
+	keySet       model.KeySetInfo
+	fetcher      backgroundfetcher.BackgroundFetcher
+	subscribers  keyevent.Subscribers
+	issuer       string
+	pending      PendingKeySource
+}
+
+// PendingKeySource provides keys that are published but not signing yet,
+// such as an S3KeyFetch.
+type PendingKeySource interface {
+	PendingKeys() []model.PublicKeyJWK
 }
 
+// retiringKey is a previous signing key that is kept until the last token it
+// signed has expired.
+type retiringKey struct {
+	jwk   model.PublicKeyJWK
+	until time.Time
+}
+
+// NewTokenGenerator signs with the key returned by fetch and checks for a new
+// one every refreshInterval. It waits up to DefaultActiveKeyWait for a
+// pending key to become active and panics if fetch fails.
 func NewTokenGenerator(
 	refreshInterval time.Duration,
 	fetch func() (*model.PrivateKeyJWK, error),
 ) *TokenGeneratorImpl {
-	key, err := fetch()
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultActiveKeyWait)
+	defer cancel()
+	tokenGenerator, err := NewTokenGeneratorContext(ctx, refreshInterval, fetch)
 	if err != nil {
 		panic(err)
 	}
+	return tokenGenerator
+}
+
+// NewTokenGeneratorContext is NewTokenGenerator waiting for an active key
+// until ctx is done. It returns ErrNoActiveKey if no key became active and
+// the errors of fetch instead of panicking.
+func NewTokenGeneratorContext(
+	ctx context.Context,
+	refreshInterval time.Duration,
+	fetch func() (*model.PrivateKeyJWK, error),
+) (*TokenGeneratorImpl, error) {
+	key, err := fetch()
+	for errors.Is(err, ErrNoActiveKey) {
+		// A freshly rotated JWKS only has a pending key until it has
+		// propagated to validators.
+		fmt.Println("waiting for an active signing key: ", err)
+		select {
+		case <-ctx.Done():
+			return nil, errors.Join(err, ctx.Err())
+		case <-time.After(activeKeyPollInterval):
+		}
+		key, err = fetch()
+	}
+	if err != nil {
+		return nil, err
+	}
 	factory := converter.ConvertFactory{}
 	converter := factory.JwkToPrivateConverter()
 	privateKey, err := converter.Convert(*key)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	result := make(chan *model.PrivateKeyJWK)
-	fetcher := &PrivateKeyFetcher{
-		fetch:  fetchKey,
-		result: result,
-		ticker: time.NewTicker(refreshInterval),
-	}
+	fetcher := NewPrivateKeyFetcher(refreshInterval, fetch, result)
 	tokenGenerator := &TokenGeneratorImpl{
 		converter:  converter,
 		privateKey: privateKey,
 		kid:        key.Kid,
+		signingKey: key.Public(),
+		keySet:     model.NewKeySetInfo([]string{key.Kid}, model.KeySetInfo{}),
 		fetcher:    fetcher,
 	}
 	fetcher.Start()
 	tokenGenerator.fetchPrivateKey(result)
-	return tokenGenerator
+	return tokenGenerator, nil
 }
 
+// Generate mints a token without scopes for the demo subject
+// "1234567890". Use GenerateWithScopes to mint tokens for a real subject.
 func (t *TokenGeneratorImpl) Generate() (string, error) {
-	claim := model.JwtClaim{
+	return t.GenerateWithScopes("1234567890")
+}
+
+// GenerateWithScopes mints a token for subject granting scopes.
+func (t *TokenGeneratorImpl) GenerateWithScopes(subject string, scopes ...string) (string, error) {
+	return t.GenerateClaims(&model.JwtClaim{
+		Scope: strings.Join(scopes, " "),
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(15 * time.Minute)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			Subject:   "1234567890",
+			Subject: subject,
 		},
+	})
+}
+
+// GenerateBoundToKey mints a token for subject granting scopes that is only
+// usable with DPoP proofs signed by the key with thumbprint jkt.
+func (t *TokenGeneratorImpl) GenerateBoundToKey(subject string, jkt string, scopes ...string) (string, error) {
+	return t.GenerateClaims(&model.JwtClaim{
+		Scope: strings.Join(scopes, " "),
+		Cnf:   &model.Confirmation{Jkt: jkt},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: subject,
+		},
+	})
+}
+
+// GenerateBoundToCertificate mints a token for subject granting scopes that
+// is only usable over mutual TLS connections authenticated with cert.
+func (t *TokenGeneratorImpl) GenerateBoundToCertificate(subject string, cert *x509.Certificate, scopes ...string) (string, error) {
+	return t.GenerateClaims(&model.JwtClaim{
+		Scope: strings.Join(scopes, " "),
+		Cnf:   &model.Confirmation{X5tS256: model.CertificateThumbprint(cert)},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: subject,
+		},
+	})
+}
+
+// SetIssuer sets the iss claim of generated tokens.
+func (t *TokenGeneratorImpl) SetIssuer(issuer string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.issuer = issuer
+}
+
+// GenerateClaims signs claims with the current key. The iss, jti, iat and exp
+// claims are filled in when unset, with exp TokenTTL after iat.
+func (t *TokenGeneratorImpl) GenerateClaims(claims *model.JwtClaim) (string, error) {
+	if claims.ID == "" {
+		jti, err := newJti()
+		if err != nil {
+			return "", err
+		}
+		claims.ID = jti
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claim)
-	token.Header["kid"] = t.kid
-	tokenStr, err := token.SignedString(t.privateKey)
+	if claims.IssuedAt == nil {
+		claims.IssuedAt = jwt.NewNumericDate(time.Now())
+	}
+	if claims.ExpiresAt == nil {
+		claims.ExpiresAt = jwt.NewNumericDate(claims.IssuedAt.Add(TokenTTL))
+	}
+	t.mu.RLock()
+	if claims.Issuer == "" {
+		claims.Issuer = t.issuer
+	}
+	t.mu.RUnlock()
+	privateKey, kid := t.getPrivateKey()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	tokenStr, err := token.SignedString(privateKey)
 	if err != nil {
 		return "", err
 	}
 	return tokenStr, nil
 }
 
-func (t *TokenGeneratorImpl) getPrivateKey() *rsa.PrivateKey {
+// newJti returns a random token ID so single tokens can be revoked.
+func newJti() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// OnKeysChanged registers fn to be called after the signing kid changes.
+func (t *TokenGeneratorImpl) OnKeysChanged(fn keyevent.KeysChangedFunc) {
+	t.subscribers.Subscribe(fn)
+}
+
+func (t *TokenGeneratorImpl) getPrivateKey() (*rsa.PrivateKey, string) {
 	t.mu.RLock()
 	defer t.mu.RUnlock()
-	return t.privateKey
+	return t.privateKey, t.kid
 }
 
-func (t *TokenGeneratorImpl) updatePrivateKey(privateKey *rsa.PrivateKey) {
+// SetPendingKeySource publishes the pending keys of source with PublicKeys,
+// so validators learn a key before the generator signs with it.
+func (t *TokenGeneratorImpl) SetPendingKeySource(source PendingKeySource) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	t.pending = source
+}
+
+// PublicKeys returns the public JWKs of the pending keys and the current
+// signing key followed by the retiring keys whose tokens may still be valid,
+// most recent first.
+func (t *TokenGeneratorImpl) PublicKeys() []model.PublicKeyJWK {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	now := time.Now()
+	var keys []model.PublicKeyJWK
+	if t.pending != nil {
+		for _, key := range t.pending.PendingKeys() {
+			if key.Kid != t.signingKey.Kid {
+				keys = append(keys, key)
+			}
+		}
+	}
+	keys = append(keys, t.signingKey)
+	for i := len(t.retiringKeys) - 1; i >= 0; i-- {
+		if now.Before(t.retiringKeys[i].until) {
+			keys = append(keys, t.retiringKeys[i].jwk)
+		}
+	}
+	return keys
+}
+
+func (t *TokenGeneratorImpl) updatePrivateKey(privateKey *rsa.PrivateKey, jwk model.PublicKeyJWK) {
+	t.mu.Lock()
+	old := t.keySet
+	now := time.Now()
+	retiring := make([]retiringKey, 0, len(t.retiringKeys)+1)
+	for _, key := range t.retiringKeys {
+		if key.jwk.Kid != jwk.Kid && now.Before(key.until) {
+			retiring = append(retiring, key)
+		}
+	}
+	if t.signingKey.Kid != jwk.Kid {
+		previous := t.signingKey
+		previous.Status = model.KeyStatusRetiring
+		retiring = append(retiring, retiringKey{jwk: previous, until: now.Add(TokenTTL)})
+	}
+	t.retiringKeys = retiring
 	t.privateKey = privateKey
+	t.kid = jwk.Kid
+	t.signingKey = jwk
+	t.keySet = model.NewKeySetInfo([]string{jwk.Kid}, old)
+	current := t.keySet
+	t.mu.Unlock()
+	if current.Changed() {
+		t.subscribers.Notify(old, current)
+	}
 }
 
 func (t *TokenGeneratorImpl) fetchPrivateKey(result chan *model.PrivateKeyJWK) {
@@ -100,42 +302,103 @@ func (t *TokenGeneratorImpl) fetchPrivateKey(result chan *model.PrivateKeyJWK) {
 					fmt.Println("background convert error: ", err)
 					continue
 				}
-				t.updatePrivateKey(privateKey)
-				t.privateKey = privateKey
+				t.updatePrivateKey(privateKey, jwks.Public())
 			}
 		}
 	}(result)
 }
 
-func fetchKey() (*model.PrivateKeyJWK, error) {
-	ctx := context.Background()
-	client := storage.InitS3Client()
-	res, err := client.GetObject(ctx, &s3.GetObjectInput{
-		Key:    aws.String(".well-known/jwks.json"),
-		Bucket: aws.String("goback-end-shared-bucket"),
-	})
+// NewMemoryPrivateKeyFetch returns a fetch function that always returns key,
+// for tests and single-instance deployments without S3.
+func NewMemoryPrivateKeyFetch(key *rsa.PrivateKey) (func() (*model.PrivateKeyJWK, error), error) {
+	factory := converter.ConvertFactory{}
+	jwk, err := factory.PrivateKeyToJwkConverter().Convert(key)
 	if err != nil {
 		return nil, err
 	}
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
+	fetched := false
+	return func() (*model.PrivateKeyJWK, error) {
+		if fetched {
+			return nil, storage.ErrNotModified
+		}
+		fetched = true
+		return &jwk, nil
+	}, nil
+}
+
+// NewS3PrivateKeyFetch returns the Fetch method of a new S3KeyFetch.
+func NewS3PrivateKeyFetch(client storage.GetObjectClient, propagationDelay time.Duration) func() (*model.PrivateKeyJWK, error) {
+	return NewS3KeyFetch(client, propagationDelay).Fetch
+}
+
+// S3KeyFetch reads the signing key from the JWKS published to S3. A newly
+// published key is only picked once it has been in the JWKS for
+// propagationDelay, so validators know it before tokens signed with it show
+// up.
+type S3KeyFetch struct {
+	client           storage.GetObjectClient
+	object           *storage.ConditionalObject
+	propagationDelay time.Duration
+	mu               sync.Mutex
+	jwks             *model.JWKS
+	currentKid       string
+	now              func() time.Time
+}
+
+func NewS3KeyFetch(client storage.GetObjectClient, propagationDelay time.Duration) *S3KeyFetch {
+	return &S3KeyFetch{
+		client:           client,
+		object:           storage.NewConditionalObject(client, "goback-end-shared-bucket", ".well-known/jwks.json"),
+		propagationDelay: propagationDelay,
+		now:              time.Now,
 	}
-	var jwks model.JWKS
-	err = json.Unmarshal(body, &jwks)
-	if err != nil {
+}
+
+// Fetch returns the private key of the JWKS signing key. It returns
+// storage.ErrNotModified while the signing kid is unchanged and
+// ErrNoActiveKey while the only keys are pending.
+func (f *S3KeyFetch) Fetch() (*model.PrivateKeyJWK, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	ctx := context.Background()
+	body, err := f.object.Get(ctx)
+	if err != nil && !errors.Is(err, storage.ErrNotModified) {
 		return nil, err
 	}
-	// fmt.Println(jwks)
-	keyName := jwks.Keys[0].Kid
-	// fmt.Println("keyName: ", keyName)
-	res, err = client.GetObject(ctx, &s3.GetObjectInput{
-		Key:    aws.String(fmt.Sprintf("jwk-private-%s.json", keyName)),
+	if err == nil {
+		var latest model.JWKS
+		err = json.Unmarshal(body, &latest)
+		if err != nil {
+			f.object.Invalidate()
+			return nil, err
+		}
+		f.jwks = &latest
+	}
+	if f.jwks == nil {
+		return nil, errors.New("jwks not loaded")
+	}
+	now := f.now()
+	signingKey, ok := f.jwks.SigningKey(now, f.propagationDelay)
+	if !ok {
+		if f.currentKid != "" {
+			return nil, storage.ErrNotModified
+		}
+		if len(f.pendingKeys(now)) > 0 {
+			return nil, ErrNoActiveKey
+		}
+		return nil, errors.New("jwks has no active key")
+	}
+	if signingKey.Kid == f.currentKid {
+		return nil, storage.ErrNotModified
+	}
+	res, err := f.client.GetObject(ctx, &s3.GetObjectInput{
+		Key:    aws.String(fmt.Sprintf("jwk-private-%s.json", signingKey.Kid)),
 		Bucket: aws.String("go-api-bucket-v1-21-6-2025"),
 	})
 	if err != nil {
 		return nil, err
 	}
+	defer res.Body.Close()
 	body, err = io.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
@@ -145,5 +408,28 @@ func fetchKey() (*model.PrivateKeyJWK, error) {
 	if err != nil {
 		return nil, err
 	}
+	f.currentKid = signingKey.Kid
 	return &privateKey, nil
 }
+
+// PendingKeys returns the keys of the last fetched JWKS that are still
+// propagating.
+func (f *S3KeyFetch) PendingKeys() []model.PublicKeyJWK {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.pendingKeys(f.now())
+}
+
+func (f *S3KeyFetch) pendingKeys(now time.Time) []model.PublicKeyJWK {
+	if f.jwks == nil {
+		return nil
+	}
+	var pending []model.PublicKeyJWK
+	for _, key := range f.jwks.Keys {
+		if key.State(now, f.propagationDelay) == model.KeyStatusPending {
+			key.Status = model.KeyStatusPending
+			pending = append(pending, key)
+		}
+	}
+	return pending
+}
@@ -0,0 +1,173 @@
+package generator
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type stubPendingKeys []model.PublicKeyJWK
+
+func (s stubPendingKeys) PendingKeys() []model.PublicKeyJWK {
+	return s
+}
+
+func newTestGenerator(t *testing.T) *TokenGeneratorImpl {
+	t.Helper()
+	key, err := converter.GenerateRsaKey(converter.MinRsaKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetch, err := NewMemoryPrivateKeyFetch(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewTokenGenerator(time.Hour, fetch)
+}
+
+func TestPublicKeysIncludesPendingKeys(t *testing.T) {
+	generator := newTestGenerator(t)
+	signingKid := generator.PublicKeys()[0].Kid
+	pending := model.PublicKeyJWK{Kid: "pending", Kty: "RSA", Status: model.KeyStatusPending}
+	// A source that still lists the signing key must not duplicate it.
+	generator.SetPendingKeySource(stubPendingKeys{pending, {Kid: signingKid}})
+
+	keys := generator.PublicKeys()
+	if len(keys) != 2 || keys[0].Kid != "pending" || keys[1].Kid != signingKid {
+		t.Fatalf("kids = %v, want [pending %s]", (&model.JWKS{Keys: keys}).Kids(), signingKid)
+	}
+	_, kid := generator.getPrivateKey()
+	if kid != signingKid {
+		t.Errorf("signing kid = %s, want %s", kid, signingKid)
+	}
+}
+
+func TestGenerateWithScopesUsesSubject(t *testing.T) {
+	generator := newTestGenerator(t)
+	token, err := generator.GenerateWithScopes("user-1", "read", "write")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &model.JwtClaim{}
+	_, _, err = jwt.NewParser().ParseUnverified(token, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-1" || claims.Scope != "read write" {
+		t.Errorf("sub = %q, scope = %q, want user-1 and read write", claims.Subject, claims.Scope)
+	}
+}
+
+// fakeBucket serves objects by key.
+type fakeBucket map[string][]byte
+
+func (b fakeBucket) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	body, ok := b[aws.ToString(params.Key)]
+	if !ok {
+		return nil, errors.New("no such key")
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+// publish stores a new private key in bucket and returns its public JWK
+// published at nbf.
+func publish(t *testing.T, bucket fakeBucket, kid string, nbf time.Time) model.PublicKeyJWK {
+	t.Helper()
+	key, err := converter.GenerateRsaKey(converter.MinRsaKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := converter.ConvertFactory{}.PrivateKeyToJwkConverter().Convert(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk.Kid = kid
+	body, err := json.Marshal(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket["jwk-private-"+kid+".json"] = body
+	public := jwk.Public()
+	public.Nbf = nbf.Unix()
+	return public
+}
+
+func setJwks(t *testing.T, bucket fakeBucket, keys ...model.PublicKeyJWK) {
+	t.Helper()
+	body, err := json.Marshal(model.JWKS{Keys: keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket[".well-known/jwks.json"] = body
+}
+
+func TestS3KeyFetchWaitsForPropagation(t *testing.T) {
+	const delay = 10 * time.Minute
+	start := time.Unix(1_700_000_000, 0)
+	now := start
+	bucket := fakeBucket{}
+	first := publish(t, bucket, "first", start.Add(-time.Hour))
+	setJwks(t, bucket, first)
+	fetch := NewS3KeyFetch(bucket, delay)
+	fetch.now = func() time.Time { return now }
+
+	key, err := fetch.Fetch()
+	if err != nil || key.Kid != "first" {
+		t.Fatalf("first fetch = %v, %v, want first", key, err)
+	}
+	_, err = fetch.Fetch()
+	if !errors.Is(err, storage.ErrNotModified) {
+		t.Fatalf("unchanged jwks: error = %v, want ErrNotModified", err)
+	}
+
+	second := publish(t, bucket, "second", start)
+	setJwks(t, bucket, second, first)
+	_, err = fetch.Fetch()
+	if !errors.Is(err, storage.ErrNotModified) {
+		t.Fatalf("pending key: error = %v, want ErrNotModified", err)
+	}
+	pending := fetch.PendingKeys()
+	if len(pending) != 1 || pending[0].Kid != "second" || pending[0].Status != model.KeyStatusPending {
+		t.Fatalf("pending keys = %+v, want second as pending", pending)
+	}
+
+	now = start.Add(delay)
+	key, err = fetch.Fetch()
+	if err != nil || key.Kid != "second" {
+		t.Fatalf("fetch after propagation = %v, %v, want second", key, err)
+	}
+	if pending := fetch.PendingKeys(); len(pending) != 0 {
+		t.Errorf("pending keys after propagation = %+v, want none", pending)
+	}
+}
+
+func TestS3KeyFetchOnlyPendingKeys(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	bucket := fakeBucket{}
+	setJwks(t, bucket, publish(t, bucket, "new", now))
+	fetch := NewS3KeyFetch(bucket, time.Minute)
+	fetch.now = func() time.Time { return now }
+
+	_, err := fetch.Fetch()
+	if !errors.Is(err, ErrNoActiveKey) {
+		t.Fatalf("error = %v, want ErrNoActiveKey", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = NewTokenGeneratorContext(ctx, time.Hour, fetch.Fetch)
+	if !errors.Is(err, ErrNoActiveKey) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("NewTokenGeneratorContext error = %v, want ErrNoActiveKey after the deadline", err)
+	}
+}
@@ -0,0 +1,71 @@
+package jwks
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+// Path is where the JWKS is conventionally served.
+const Path = "/.well-known/jwks.json"
+
+// KeySource provides the public keys to publish, such as a TokenGeneratorImpl.
+type KeySource interface {
+	PublicKeys() []model.PublicKeyJWK
+}
+
+type Handler struct {
+	source KeySource
+	maxAge time.Duration
+}
+
+func NewHandler(source KeySource, maxAge time.Duration) *Handler {
+	return &Handler{
+		source: source,
+		maxAge: maxAge,
+	}
+}
+
+// ServeHTTP writes the JWKS with a strong ETag derived from its content, so
+// the ETag only changes when the keys are rotated.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := json.Marshal(model.JWKS{Keys: h.source.PublicKeys()})
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + base64.RawURLEncoding.EncodeToString(sum[:]) + `"`
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.maxAge.Seconds())))
+	if matchesEtag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write(body)
+}
+
+func matchesEtag(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package jwks
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+// staticKeys publishes a fixed list of keys.
+type staticKeys []model.PublicKeyJWK
+
+func (k staticKeys) PublicKeys() []model.PublicKeyJWK {
+	return k
+}
+
+func serve(handler http.Handler, method string, ifNoneMatch string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, Path, nil)
+	if ifNoneMatch != "" {
+		r.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestHandler(t *testing.T) {
+	keys := staticKeys{{Kid: "pending", Kty: "RSA"}, {Kid: "signing", Kty: "RSA"}, {Kid: "retiring", Kty: "RSA"}}
+	handler := NewHandler(keys, 5*time.Minute)
+
+	w := serve(handler, http.MethodGet, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=300" {
+		t.Errorf("Cache-Control = %q, want public, max-age=300", got)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	var published model.JWKS
+	err := json.Unmarshal(w.Body.Bytes(), &published)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kids := published.Kids()
+	if len(kids) != 3 || kids[0] != "pending" || kids[1] != "signing" || kids[2] != "retiring" {
+		t.Errorf("kids = %v, want the source order", kids)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" || etag[0] != '"' {
+		t.Fatalf("ETag = %q, want a strong ETag", etag)
+	}
+	// The same keys always get the same ETag.
+	if again := serve(handler, http.MethodGet, "").Header().Get("ETag"); again != etag {
+		t.Errorf("ETag = %q on the second request, want %q", again, etag)
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		ifNoneMatch string
+		wantStatus  int
+		wantBody    bool
+	}{
+		{"matching etag", http.MethodGet, etag, http.StatusNotModified, false},
+		{"one of several etags", http.MethodGet, `"other", ` + etag, http.StatusNotModified, false},
+		{"any etag", http.MethodGet, "*", http.StatusNotModified, false},
+		{"stale etag", http.MethodGet, `"other"`, http.StatusOK, true},
+		{"head", http.MethodHead, "", http.StatusOK, false},
+		{"head with matching etag", http.MethodHead, etag, http.StatusNotModified, false},
+		{"post", http.MethodPost, "", http.StatusMethodNotAllowed, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(handler, tt.method, tt.ifNoneMatch)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if (w.Body.Len() > 0) != tt.wantBody {
+				t.Errorf("body = %q, want body %v", w.Body, tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusMethodNotAllowed {
+				if got := w.Header().Get("Allow"); got != "GET, HEAD" {
+					t.Errorf("Allow = %q, want GET, HEAD", got)
+				}
+				return
+			}
+			if got := w.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			if got := w.Header().Get("Cache-Control"); got != "public, max-age=300" {
+				t.Errorf("Cache-Control = %q, want public, max-age=300", got)
+			}
+		})
+	}
+}
+
+func TestHandlerEtagChangesWithKeys(t *testing.T) {
+	before := serve(NewHandler(staticKeys{{Kid: "a"}, {Kid: "b"}}, time.Minute), http.MethodGet, "").Header().Get("ETag")
+	after := serve(NewHandler(staticKeys{{Kid: "c"}, {Kid: "a"}, {Kid: "b"}}, time.Minute), http.MethodGet, "").Header().Get("ETag")
+	if before == after {
+		t.Errorf("ETag %q did not change when a key was added", before)
+	}
+	w := serve(NewHandler(staticKeys{{Kid: "c"}, {Kid: "a"}, {Kid: "b"}}, time.Minute), http.MethodGet, before)
+	if w.Code != http.StatusOK {
+		t.Errorf("status = %d for the ETag of the previous keys, want 200", w.Code)
+	}
+}
@@ -0,0 +1,43 @@
+package keyevent
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+type KeysChangedFunc func(old, current model.KeySetInfo)
+
+// Subscribers holds the callbacks fired when a key set is rotated.
+type Subscribers struct {
+	mu   sync.RWMutex
+	subs []KeysChangedFunc
+}
+
+func (s *Subscribers) Subscribe(fn KeysChangedFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs = append(s.subs, fn)
+}
+
+// Notify calls every subscriber in registration order. A panicking
+// subscriber does not stop the others.
+func (s *Subscribers) Notify(old, current model.KeySetInfo) {
+	s.mu.RLock()
+	subs := make([]KeysChangedFunc, len(s.subs))
+	copy(subs, s.subs)
+	s.mu.RUnlock()
+	for _, fn := range subs {
+		notify(fn, old, current)
+	}
+}
+
+func notify(fn KeysChangedFunc, old, current model.KeySetInfo) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Println("keys changed subscriber panic: ", r)
+		}
+	}()
+	fn(old, current)
+}
@@ -0,0 +1,38 @@
+package keyevent
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+func TestNotifyIsolatesPanickingSubscribers(t *testing.T) {
+	var subscribers Subscribers
+	var calls []string
+	subscribers.Subscribe(func(old, current model.KeySetInfo) {
+		calls = append(calls, "first")
+	})
+	subscribers.Subscribe(func(old, current model.KeySetInfo) {
+		calls = append(calls, "panicking")
+		panic("subscriber failed")
+	})
+	subscribers.Subscribe(func(old, current model.KeySetInfo) {
+		if len(current.Added) != 1 || current.Added[0] != "b" {
+			t.Errorf("added = %v, want [b]", current.Added)
+		}
+		calls = append(calls, "last")
+	})
+
+	old := model.NewKeySetInfo([]string{"a"}, model.KeySetInfo{})
+	subscribers.Notify(old, model.NewKeySetInfo([]string{"b"}, old))
+	want := []string{"first", "panicking", "last"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("calls = %v, want %v", calls, want)
+	}
+}
+
+func TestNotifyWithoutSubscribers(t *testing.T) {
+	var subscribers Subscribers
+	subscribers.Notify(model.KeySetInfo{}, model.KeySetInfo{Kids: []string{"a"}, Added: []string{"a"}})
+}
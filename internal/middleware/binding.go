@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"errors"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+var ErrCertificateMismatch = errors.New("token is bound to a different client certificate")
+
+// CheckBearerBinding checks that claims may be used as a bearer token over a
+// connection in state, which is nil without TLS. DPoP-bound tokens are never
+// accepted as bearer tokens.
+func CheckBearerBinding(claims *model.JwtClaim, state *tls.ConnectionState) error {
+	if claims.Cnf != nil && claims.Cnf.Jkt != "" {
+		return ErrBoundToken
+	}
+	return CheckCertificateBinding(claims, state)
+}
+
+// CheckCertificateBinding checks that a certificate-bound token is presented
+// over mutual TLS with the certificate it is bound to, as in RFC 8705
+// section 3.
+func CheckCertificateBinding(claims *model.JwtClaim, state *tls.ConnectionState) error {
+	if claims.Cnf == nil || claims.Cnf.X5tS256 == "" {
+		return nil
+	}
+	if state == nil || len(state.PeerCertificates) == 0 {
+		return ErrCertificateMismatch
+	}
+	thumbprint := model.CertificateThumbprint(state.PeerCertificates[0])
+	if subtle.ConstantTimeCompare([]byte(thumbprint), []byte(claims.Cnf.X5tS256)) != 1 {
+		return ErrCertificateMismatch
+	}
+	return nil
+}
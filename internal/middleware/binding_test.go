@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/testutil"
+)
+
+func TestCheckCertificateBinding(t *testing.T) {
+	cert := testutil.NewClientCert(t, "orders")
+	other := testutil.NewClientCert(t, "billing")
+	bound := &model.JwtClaim{Cnf: &model.Confirmation{X5tS256: model.CertificateThumbprint(cert)}}
+	tests := []struct {
+		name    string
+		claims  *model.JwtClaim
+		state   *tls.ConnectionState
+		wantErr error
+	}{
+		{"bound with its certificate", bound, &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, nil},
+		{"bound with another certificate", bound, &tls.ConnectionState{PeerCertificates: []*x509.Certificate{other}}, ErrCertificateMismatch},
+		{"bound without a certificate", bound, &tls.ConnectionState{}, ErrCertificateMismatch},
+		{"bound without tls", bound, nil, ErrCertificateMismatch},
+		{"unbound without tls", &model.JwtClaim{}, nil, nil},
+		{"unbound with a certificate", &model.JwtClaim{}, &tls.ConnectionState{PeerCertificates: []*x509.Certificate{other}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckCertificateBinding(tt.claims, tt.state)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckBearerBindingRejectsDPoPTokens(t *testing.T) {
+	claims := &model.JwtClaim{Cnf: &model.Confirmation{Jkt: "thumbprint"}}
+	err := CheckBearerBinding(claims, nil)
+	if !errors.Is(err, ErrBoundToken) {
+		t.Errorf("error = %v, want ErrBoundToken", err)
+	}
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"context"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+type claimsKey struct{}
+
+func WithClaims(ctx context.Context, claims *model.JwtClaim) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims stored by the authentication middleware.
+func ClaimsFromContext(ctx context.Context) (*model.JwtClaim, bool) {
+	claims, ok := ctx.Value(claimsKey{}).(*model.JwtClaim)
+	return claims, ok
+}
+
+// SubjectFromContext returns the sub claim of the authenticated token.
+func SubjectFromContext(ctx context.Context) (string, bool) {
+	claims, ok := ClaimsFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	return claims.Subject, true
+}
@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/dpop"
+	"github.com/CalvinCYCheung/go_token_validator/internal/generator"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/replay"
+	"github.com/CalvinCYCheung/go_token_validator/internal/validator"
+)
+
+func TestAuthenticatorDPoP(t *testing.T) {
+	signingKey, err := converter.GenerateRsaKey(converter.MinRsaKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetch, err := generator.NewMemoryPrivateKeyFetch(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := generator.NewTokenGenerator(time.Hour, fetch)
+	v := validator.NewRsaKeyValidator(time.Hour, func() (*model.JWKS, error) {
+		return &model.JWKS{Keys: tokens.PublicKeys()}, nil
+	})
+	defer v.Close()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jkt, err := dpop.Thumbprint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bound, err := tokens.GenerateBoundToKey("user-1", jkt, "orders:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unbound, err := tokens.GenerateWithScopes("user-1", "orders:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const url = "https://api.example/orders"
+	tests := []struct {
+		name       string
+		scheme     string
+		token      string
+		key        *ecdsa.PrivateKey
+		proofUrl   string
+		proofToken string
+		wantStatus int
+		wantError  string
+	}{
+		{"bound token with proof", "DPoP", bound, key, url, bound, http.StatusOK, ""},
+		{"without proof", "DPoP", bound, nil, "", "", http.StatusUnauthorized, ErrorInvalidDPoPProof},
+		{"proof of another key", "DPoP", bound, other, url, bound, http.StatusUnauthorized, ErrorInvalidToken},
+		{"proof for another token", "DPoP", bound, key, url, unbound, http.StatusUnauthorized, ErrorInvalidDPoPProof},
+		{"proof for another url", "DPoP", bound, key, "https://api.example/billing", bound, http.StatusUnauthorized, ErrorInvalidDPoPProof},
+		{"unbound token", "DPoP", unbound, key, url, unbound, http.StatusUnauthorized, ErrorInvalidToken},
+		{"invalid token", "DPoP", "invalid", key, url, "invalid", http.StatusUnauthorized, ErrorInvalidToken},
+		{"bound token as bearer", "Bearer", bound, nil, "", "", http.StatusUnauthorized, ErrorInvalidToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := NewAuthenticator(v, "test")
+			authenticator.SetDPoPVerifier(dpop.NewVerifier(time.Minute, replay.NewMemoryCache()), "https://api.example")
+			var claims *model.JwtClaim
+			handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				claims, _ = ClaimsFromContext(r.Context())
+			}))
+			r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+			r.Header.Set("Authorization", tt.scheme+" "+tt.token)
+			if tt.key != nil {
+				proof, err := dpop.NewProof(tt.key, http.MethodGet, tt.proofUrl, tt.proofToken)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r.Header.Set(dpop.Header, proof)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+			challenge := w.Header().Get("WWW-Authenticate")
+			if tt.wantError == "" {
+				if claims == nil || claims.Subject != "user-1" {
+					t.Errorf("claims = %+v, want the claims of user-1", claims)
+				}
+				return
+			}
+			if !strings.Contains(challenge, `error="`+tt.wantError+`"`) {
+				t.Errorf("WWW-Authenticate = %q, want error %s", challenge, tt.wantError)
+			}
+			if tt.scheme == "DPoP" && !strings.HasPrefix(challenge, "DPoP ") {
+				t.Errorf("WWW-Authenticate = %q, want a DPoP challenge", challenge)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Extractor reads a token from a request. It returns ErrMissingToken when the
+// request carries no token in the place it looks at.
+type Extractor interface {
+	Extract(r *http.Request) (string, error)
+}
+
+type ExtractorFunc func(r *http.Request) (string, error)
+
+func (f ExtractorFunc) Extract(r *http.Request) (string, error) {
+	return f(r)
+}
+
+// BearerHeader reads the Authorization header with a case-insensitive Bearer
+// scheme.
+func BearerHeader() Extractor {
+	return ExtractorFunc(BearerToken)
+}
+
+// Header reads the raw token from a custom header, such as X-Access-Token.
+func Header(name string) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		return nonEmpty(r.Header.Get(name))
+	})
+}
+
+func Cookie(name string) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return "", ErrMissingToken
+		}
+		return nonEmpty(cookie.Value)
+	})
+}
+
+// QueryParam reads the token from the URL query. Tokens in URLs end up in
+// access logs, so only add it to a chain where nothing else is possible.
+func QueryParam(name string) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		return nonEmpty(r.URL.Query().Get(name))
+	})
+}
+
+// WebSocketProtocol reads the token from the Sec-WebSocket-Protocol entry that
+// starts with prefix, since browsers cannot set headers on WebSocket requests.
+func WebSocketProtocol(prefix string) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		for _, header := range r.Header.Values("Sec-WebSocket-Protocol") {
+			for _, protocol := range strings.Split(header, ",") {
+				protocol = strings.TrimSpace(protocol)
+				if token, ok := strings.CutPrefix(protocol, prefix); ok {
+					return nonEmpty(token)
+				}
+			}
+		}
+		return "", ErrMissingToken
+	})
+}
+
+// FormField reads the token from a form-encoded body as described in
+// RFC 6750 section 2.2. GET requests are ignored.
+func FormField(name string) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		if r.Method == http.MethodGet || r.Body == nil {
+			return "", ErrMissingToken
+		}
+		mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil || mediaType != "application/x-www-form-urlencoded" {
+			return "", ErrMissingToken
+		}
+		err = r.ParseForm()
+		if err != nil {
+			return "", ErrMalformedToken
+		}
+		return nonEmpty(r.PostForm.Get(name))
+	})
+}
+
+// Chain tries extractors in priority order and returns the first token found.
+// A malformed token stops the chain instead of falling through.
+func Chain(extractors ...Extractor) Extractor {
+	return ExtractorFunc(func(r *http.Request) (string, error) {
+		for _, extractor := range extractors {
+			token, err := extractor.Extract(r)
+			if errors.Is(err, ErrMissingToken) {
+				continue
+			}
+			return token, err
+		}
+		return "", ErrMissingToken
+	})
+}
+
+func nonEmpty(token string) (string, error) {
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", ErrMissingToken
+	}
+	return token, nil
+}
@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExtractors(t *testing.T) {
+	get := func(target string, header ...string) *http.Request {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			r.Header.Add(header[i], header[i+1])
+		}
+		return r
+	}
+	post := func(contentType string, body string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set("Content-Type", contentType)
+		return r
+	}
+	tests := []struct {
+		name      string
+		extractor Extractor
+		request   *http.Request
+		wantToken string
+		wantErr   error
+	}{
+		{"header", Header("X-Access-Token"), get("/", "X-Access-Token", " abc "), "abc", nil},
+		{"missing header", Header("X-Access-Token"), get("/"), "", ErrMissingToken},
+		{"empty header", Header("X-Access-Token"), get("/", "X-Access-Token", "  "), "", ErrMissingToken},
+		{"cookie", Cookie("access_token"), get("/", "Cookie", "other=1; access_token=abc"), "abc", nil},
+		{"missing cookie", Cookie("access_token"), get("/", "Cookie", "other=1"), "", ErrMissingToken},
+		{"empty cookie", Cookie("access_token"), get("/", "Cookie", "access_token="), "", ErrMissingToken},
+		{"query", QueryParam("access_token"), get("/?access_token=abc"), "abc", nil},
+		{"missing query", QueryParam("access_token"), get("/?other=abc"), "", ErrMissingToken},
+		{"websocket protocol", WebSocketProtocol("token."), get("/", "Sec-WebSocket-Protocol", "chat, token.abc"), "abc", nil},
+		{"websocket protocol in a second header", WebSocketProtocol("token."), get("/", "Sec-WebSocket-Protocol", "chat", "Sec-WebSocket-Protocol", "token.abc"), "abc", nil},
+		{"websocket without token protocol", WebSocketProtocol("token."), get("/", "Sec-WebSocket-Protocol", "chat"), "", ErrMissingToken},
+		{"websocket with empty token", WebSocketProtocol("token."), get("/", "Sec-WebSocket-Protocol", "token."), "", ErrMissingToken},
+		{"form field", FormField("access_token"), post("application/x-www-form-urlencoded", "access_token=abc"), "abc", nil},
+		{"form field with charset", FormField("access_token"), post("application/x-www-form-urlencoded; charset=utf-8", "access_token=abc"), "abc", nil},
+		{"form field in json", FormField("access_token"), post("application/json", `{"access_token":"abc"}`), "", ErrMissingToken},
+		{"form field on get", FormField("access_token"), get("/?access_token=abc", "Content-Type", "application/x-www-form-urlencoded"), "", ErrMissingToken},
+		{"malformed form", FormField("access_token"), post("application/x-www-form-urlencoded", "access_token=%zz"), "", ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := tt.extractor.Extract(tt.request)
+			if !errors.Is(err, tt.wantErr) || token != tt.wantToken {
+				t.Errorf("Extract = %q, %v, want %q, %v", token, err, tt.wantToken, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestChain(t *testing.T) {
+	chain := Chain(BearerHeader(), Cookie("access_token"), QueryParam("access_token"))
+	tests := []struct {
+		name      string
+		header    string
+		cookie    string
+		query     string
+		wantToken string
+		wantErr   error
+	}{
+		{"first source wins", "Bearer header", "cookie", "query", "header", nil},
+		{"falls through missing sources", "", "", "query", "query", nil},
+		{"other scheme falls through", "Basic b3JkZXJz", "cookie", "", "cookie", nil},
+		{"malformed credential stops the chain", "Bearer a b", "cookie", "query", "", ErrMalformedToken},
+		{"nothing found", "", "", "", "", ErrMissingToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := "/"
+			if tt.query != "" {
+				target += "?access_token=" + tt.query
+			}
+			r := httptest.NewRequest(http.MethodGet, target, nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "access_token", Value: tt.cookie})
+			}
+			token, err := chain.Extract(r)
+			if !errors.Is(err, tt.wantErr) || token != tt.wantToken {
+				t.Errorf("Extract = %q, %v, want %q, %v", token, err, tt.wantToken, tt.wantErr)
+			}
+		})
+	}
+}
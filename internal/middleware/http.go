@@ -0,0 +1,309 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/dpop"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/policy"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RFC 6750 error codes.
+const (
+	ErrorInvalidRequest    = "invalid_request"
+	ErrorInvalidToken      = "invalid_token"
+	ErrorInsufficientScope = "insufficient_scope"
+	// ErrorInvalidDPoPProof is defined by RFC 9449 section 7.1.
+	ErrorInvalidDPoPProof = "invalid_dpop_proof"
+)
+
+var (
+	ErrMissingToken      = errors.New("missing bearer token")
+	ErrMalformedToken    = errors.New("malformed authorization header")
+	ErrInsufficientScope = errors.New("token does not grant the required scope")
+	ErrBoundToken        = errors.New("token is bound to a DPoP key")
+)
+
+type ClaimsValidator interface {
+	ValidateClaims(token string) (*model.JwtClaim, error)
+}
+
+// ContextClaimsValidator is implemented by validators that do I/O, such as
+// introspection, so they can stop when the request is cancelled.
+type ContextClaimsValidator interface {
+	ValidateClaimsContext(ctx context.Context, token string) (*model.JwtClaim, error)
+}
+
+// ValidateClaims validates token with ctx when validator supports it.
+func ValidateClaims(ctx context.Context, validator ClaimsValidator, token string) (*model.JwtClaim, error) {
+	if v, ok := validator.(ContextClaimsValidator); ok {
+		return v.ValidateClaimsContext(ctx, token)
+	}
+	return validator.ValidateClaims(token)
+}
+
+type Authenticator struct {
+	validator   ClaimsValidator
+	realm       string
+	extractor   Extractor
+	dpop        *dpop.Verifier
+	dpopBaseUrl string
+	expected    []jwt.ParserOption
+}
+
+// NewAuthenticator reads tokens with extractors in priority order, or from
+// the Authorization Bearer header when none are given.
+func NewAuthenticator(validator ClaimsValidator, realm string, extractors ...Extractor) *Authenticator {
+	extractor := BearerHeader()
+	if len(extractors) > 0 {
+		extractor = Chain(extractors...)
+	}
+	return &Authenticator{
+		validator: validator,
+		realm:     realm,
+		extractor: extractor,
+	}
+}
+
+// SetDPoPVerifier accepts DPoP-bound tokens sent with the DPoP scheme and a
+// proof checked by verifier. baseUrl is the external scheme and host of the
+// service for the htu check and must not be empty.
+func (a *Authenticator) SetDPoPVerifier(verifier *dpop.Verifier, baseUrl string) {
+	if baseUrl == "" {
+		panic(dpop.ErrNoBaseUrl)
+	}
+	a.dpop = verifier
+	a.dpopBaseUrl = baseUrl
+}
+
+// SetAudience rejects tokens whose aud claim does not contain audience. It
+// applies on top of the validator, which may not check aud itself.
+func (a *Authenticator) SetAudience(audience string) {
+	a.expected = append(a.expected, jwt.WithAudience(audience))
+}
+
+// SetIssuer rejects tokens whose iss claim is not issuer.
+func (a *Authenticator) SetIssuer(issuer string) {
+	a.expected = append(a.expected, jwt.WithIssuer(issuer))
+}
+
+// validate validates token and checks the expected aud and iss claims.
+func (a *Authenticator) validate(ctx context.Context, token string) (*model.JwtClaim, error) {
+	claims, err := ValidateClaims(ctx, a.validator, token)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.expected) > 0 {
+		err = jwt.NewValidator(a.expected...).Validate(claims)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// Middleware validates the bearer token of each request and stores its claims
+// in the request context. DPoP-bound tokens are rejected as bearer tokens and
+// certificate-bound tokens need the matching client certificate.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.dpop != nil {
+			token, err := DPoPToken(r)
+			if !errors.Is(err, ErrMissingToken) {
+				a.serveDPoP(w, r, next, token, err)
+				return
+			}
+		}
+		token, err := a.extractor.Extract(r)
+		if errors.Is(err, ErrMissingToken) {
+			WriteChallenge(w, a.realm, http.StatusUnauthorized, "", "", "")
+			return
+		}
+		if err != nil {
+			WriteChallenge(w, a.realm, http.StatusBadRequest, ErrorInvalidRequest, err.Error(), "")
+			return
+		}
+		claims, err := a.validate(r.Context(), token)
+		if err != nil {
+			WriteChallenge(w, a.realm, http.StatusUnauthorized, ErrorInvalidToken, err.Error(), "")
+			return
+		}
+		err = CheckBearerBinding(claims, r.TLS)
+		if err != nil {
+			WriteChallenge(w, a.realm, http.StatusUnauthorized, ErrorInvalidToken, err.Error(), "")
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+	})
+}
+
+// serveDPoP authenticates a request with the DPoP scheme as described in
+// RFC 9449 section 7.
+func (a *Authenticator) serveDPoP(w http.ResponseWriter, r *http.Request, next http.Handler, token string, err error) {
+	if err != nil {
+		WriteDPoPChallenge(w, a.realm, http.StatusBadRequest, ErrorInvalidRequest, err.Error())
+		return
+	}
+	claims, err := a.validate(r.Context(), token)
+	if err != nil {
+		WriteDPoPChallenge(w, a.realm, http.StatusUnauthorized, ErrorInvalidToken, err.Error())
+		return
+	}
+	jkt, err := a.dpop.VerifyRequest(r, a.dpopBaseUrl, token)
+	if err != nil {
+		WriteDPoPChallenge(w, a.realm, http.StatusUnauthorized, ErrorInvalidDPoPProof, err.Error())
+		return
+	}
+	if claims.Cnf == nil || claims.Cnf.Jkt != jkt {
+		WriteDPoPChallenge(w, a.realm, http.StatusUnauthorized, ErrorInvalidToken, "token is not bound to the proof key")
+		return
+	}
+	err = CheckCertificateBinding(claims, r.TLS)
+	if err != nil {
+		WriteDPoPChallenge(w, a.realm, http.StatusUnauthorized, ErrorInvalidToken, err.Error())
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(WithClaims(r.Context(), claims)))
+}
+
+// Require rejects requests whose claims fail check with 403
+// insufficient_scope. It must run after Middleware.
+func (a *Authenticator) Require(scope string, check func(claims *model.JwtClaim) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				WriteChallenge(w, a.realm, http.StatusUnauthorized, "", "", "")
+				return
+			}
+			if !check(claims) {
+				WriteChallenge(w, a.realm, http.StatusForbidden, ErrorInsufficientScope, "token does not grant the required scope", scope)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireScopes rejects tokens that lack any of scopes.
+func (a *Authenticator) RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return a.Require(strings.Join(scopes, " "), func(claims *model.JwtClaim) bool {
+		return claims.HasAllScopes(scopes...)
+	})
+}
+
+// RequireRoles rejects tokens that have none of roles.
+func (a *Authenticator) RequireRoles(roles ...string) func(http.Handler) http.Handler {
+	return a.Require("", func(claims *model.JwtClaim) bool {
+		return claims.HasAnyRole(roles...)
+	})
+}
+
+// RequirePolicy rejects tokens whose claims fail p. params supplies request
+// values such as path parameters and may be nil.
+func (a *Authenticator) RequirePolicy(p *policy.Policy, params func(r *http.Request) map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				WriteChallenge(w, a.realm, http.StatusUnauthorized, "", "", "")
+				return
+			}
+			input := policy.Input{Claims: claims}
+			if params != nil {
+				input.Params = params(r)
+			}
+			err := p.Evaluate(input)
+			if err != nil {
+				WriteChallenge(w, a.realm, http.StatusForbidden, ErrorInsufficientScope, err.Error(), "")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WriteChallenge writes an RFC 6750 error response. A request without
+// credentials gets a challenge without an error code.
+func WriteChallenge(w http.ResponseWriter, realm string, status int, code string, description string, scope string) {
+	params := challengeParams(realm, code, description)
+	if scope != "" {
+		params = append(params, fmt.Sprintf("scope=%q", scope))
+	}
+	writeChallenge(w, "Bearer", params, status, code, description)
+}
+
+// WriteDPoPChallenge writes an RFC 9449 error response, which lists the
+// accepted proof algorithms.
+func WriteDPoPChallenge(w http.ResponseWriter, realm string, status int, code string, description string) {
+	params := challengeParams(realm, code, description)
+	params = append(params, fmt.Sprintf("algs=%q", strings.Join(dpop.Algs, " ")))
+	writeChallenge(w, "DPoP", params, status, code, description)
+}
+
+func challengeParams(realm string, code string, description string) []string {
+	params := []string{fmt.Sprintf("realm=%q", realm)}
+	if code != "" {
+		params = append(params, fmt.Sprintf("error=%q", code))
+	}
+	if description != "" {
+		params = append(params, fmt.Sprintf("error_description=%q", sanitize(description)))
+	}
+	return params
+}
+
+func writeChallenge(w http.ResponseWriter, scheme string, params []string, status int, code string, description string) {
+	w.Header().Set("WWW-Authenticate", scheme+" "+strings.Join(params, ", "))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if code == "" {
+		code = http.StatusText(status)
+	}
+	json.NewEncoder(w).Encode(map[string]string{
+		"error":             code,
+		"error_description": description,
+	})
+}
+
+// BearerToken returns the token of a Bearer Authorization header.
+func BearerToken(r *http.Request) (string, error) {
+	return schemeToken(r, "Bearer")
+}
+
+// DPoPToken returns the token of a DPoP Authorization header.
+func DPoPToken(r *http.Request) (string, error) {
+	return schemeToken(r, "DPoP")
+}
+
+func schemeToken(r *http.Request, want string) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		return "", ErrMissingToken
+	}
+	scheme, token, _ := strings.Cut(header, " ")
+	if !strings.EqualFold(scheme, want) {
+		// Credentials for another scheme do not count.
+		return "", ErrMissingToken
+	}
+	token = strings.TrimSpace(token)
+	if token == "" || strings.ContainsAny(token, " \t") {
+		return "", ErrMalformedToken
+	}
+	return token, nil
+}
+
+// sanitize keeps error_description within the characters RFC 6750 allows.
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, s)
+}
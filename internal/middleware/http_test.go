@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/testutil"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestAuthenticatorChecksAudienceAndIssuer(t *testing.T) {
+	claims := &model.JwtClaim{RegisteredClaims: jwt.RegisteredClaims{
+		Audience: jwt.ClaimStrings{"orders"},
+		Issuer:   "https://issuer.example",
+	}}
+	tests := []struct {
+		name       string
+		audience   string
+		issuer     string
+		wantStatus int
+	}{
+		{"unchecked", "", "", http.StatusOK},
+		{"expected", "orders", "https://issuer.example", http.StatusOK},
+		{"other audience", "billing", "", http.StatusUnauthorized},
+		{"other issuer", "", "https://other.example", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := NewAuthenticator(testutil.StubValidator{"token": claims}, "test")
+			if tt.audience != "" {
+				authenticator.SetAudience(tt.audience)
+			}
+			if tt.issuer != "" {
+				authenticator.SetIssuer(tt.issuer)
+			}
+			handler := authenticator.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Authorization", "Bearer token")
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		name      string
+		header    string
+		wantToken string
+		wantErr   error
+	}{
+		{"bearer", "Bearer abc", "abc", nil},
+		{"lower case scheme", "bearer abc", "abc", nil},
+		{"upper case scheme", "BEARER abc", "abc", nil},
+		{"extra spaces", "Bearer    abc  ", "abc", nil},
+		{"no header", "", "", ErrMissingToken},
+		{"other scheme", "Basic YWxhZGRpbjpvcGVuc2VzYW1l", "", ErrMissingToken},
+		{"dpop scheme", "DPoP abc", "", ErrMissingToken},
+		{"scheme only", "Bearer", "", ErrMalformedToken},
+		{"empty token", "Bearer   ", "", ErrMalformedToken},
+		{"two tokens", "Bearer abc def", "", ErrMalformedToken},
+		{"tab in token", "Bearer abc\tdef", "", ErrMalformedToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Authorization", tt.header)
+			}
+			token, err := BearerToken(r)
+			if !errors.Is(err, tt.wantErr) || token != tt.wantToken {
+				t.Errorf("BearerToken = %q, %v, want %q, %v", token, err, tt.wantToken, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorChallenges(t *testing.T) {
+	validator := testutil.StubValidator{"reader": {Scope: "orders:read"}}
+	authenticator := NewAuthenticator(validator, "orders")
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		name          string
+		handler       http.Handler
+		authorization string
+		wantStatus    int
+		wantChallenge string
+		wantError     string
+	}{
+		{"valid token", authenticator.Middleware(ok), "Bearer reader", http.StatusOK, "", ""},
+		{"no credentials", authenticator.Middleware(ok), "", http.StatusUnauthorized, `Bearer realm="orders"`, "Unauthorized"},
+		{"other scheme", authenticator.Middleware(ok), "Basic b3JkZXJz", http.StatusUnauthorized, `Bearer realm="orders"`, "Unauthorized"},
+		{"malformed header", authenticator.Middleware(ok), "Bearer a b", http.StatusBadRequest,
+			`Bearer realm="orders", error="invalid_request", error_description="malformed authorization header"`, ErrorInvalidRequest},
+		{"invalid token", authenticator.Middleware(ok), "Bearer forged", http.StatusUnauthorized,
+			`Bearer realm="orders", error="invalid_token", error_description="unknown token"`, ErrorInvalidToken},
+		{"insufficient scope", authenticator.Middleware(authenticator.RequireScopes("orders:read", "orders:write")(ok)), "Bearer reader", http.StatusForbidden,
+			`Bearer realm="orders", error="insufficient_scope", error_description="token does not grant the required scope", scope="orders:read orders:write"`, ErrorInsufficientScope},
+		{"guard without middleware", authenticator.RequireScopes("orders:read")(ok), "Bearer reader", http.StatusUnauthorized, `Bearer realm="orders"`, "Unauthorized"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			tt.handler.ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.wantChallenge {
+				t.Errorf("WWW-Authenticate = %q, want %q", got, tt.wantChallenge)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var body map[string]string
+			err := json.Unmarshal(w.Body.Bytes(), &body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body["error"] != tt.wantError {
+				t.Errorf("error = %q, want %q", body["error"], tt.wantError)
+			}
+		})
+	}
+}
+
+func TestWriteChallengeQuoting(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteChallenge(w, "orders", http.StatusUnauthorized, ErrorInvalidToken, "bad \"token\"\\\r\nSet-Cookie: x=1 é", "")
+	want := `Bearer realm="orders", error="invalid_token", error_description="bad tokenSet-Cookie: x=1 "`
+	if got := w.Header().Get("WWW-Authenticate"); got != want {
+		t.Errorf("WWW-Authenticate = %q, want %q", got, want)
+	}
+	// The JSON body keeps the description as it was.
+	var body map[string]string
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body["error_description"], `"token"`) {
+		t.Errorf("error_description = %q, want the original description", body["error_description"])
+	}
+}
@@ -1,8 +1,181 @@
 package model
 
-import "github.com/golang-jwt/jwt/v5"
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 type JwtClaim struct {
 	Kid string `json:"kid"`
+	// Scope is the space-delimited OAuth 2.0 scope claim.
+	Scope string `json:"scope,omitempty"`
+	// Scp is the scope claim as used by some issuers, either an array or a
+	// space-delimited string.
+	Scp   StringList `json:"scp,omitempty"`
+	Roles StringList `json:"roles,omitempty"`
+	// ClientID is the OAuth client the token was issued to.
+	ClientID string `json:"client_id,omitempty"`
+	// Act identifies the party acting on behalf of the subject, as set by
+	// RFC 8693 token exchange.
+	Act *Actor `json:"act,omitempty"`
+	// Cnf binds the token to a key the client must prove it holds.
+	Cnf *Confirmation `json:"cnf,omitempty"`
 	jwt.RegisteredClaims
+	// Raw holds every claim of a parsed token, including ones without a
+	// field, for policy evaluation.
+	Raw map[string]any `json:"-"`
+}
+
+func (c *JwtClaim) UnmarshalJSON(data []byte) error {
+	// claimFields has the same fields but not this method, which avoids
+	// recursing into UnmarshalJSON.
+	type claimFields JwtClaim
+	var fields claimFields
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+	var raw map[string]any
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return err
+	}
+	*c = JwtClaim(fields)
+	c.Raw = raw
+	return nil
+}
+
+// Confirmation is an RFC 7800 cnf claim.
+type Confirmation struct {
+	// Jkt is the RFC 7638 thumbprint of a DPoP key (RFC 9449).
+	Jkt string `json:"jkt,omitempty"`
+	// X5tS256 is the thumbprint of a mutual TLS client certificate
+	// (RFC 8705).
+	X5tS256 string `json:"x5t#S256,omitempty"`
+}
+
+// Actor is an RFC 8693 act claim. Act holds the previous actor when the
+// token was exchanged more than once.
+type Actor struct {
+	Subject string `json:"sub"`
+	Act     *Actor `json:"act,omitempty"`
+}
+
+// Depth returns the number of actors in the chain.
+func (a *Actor) Depth() int {
+	depth := 0
+	for actor := a; actor != nil; actor = actor.Act {
+		depth++
+	}
+	return depth
+}
+
+// Claim returns a claim by name. Dots address nested objects, as in
+// "realm_access.roles".
+func (c *JwtClaim) Claim(name string) (any, bool) {
+	var value any = c.Raw
+	for _, part := range strings.Split(name, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// StringList unmarshals from either a JSON array or a space-delimited string.
+type StringList []string
+
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		*l = strings.Fields(value)
+		return nil
+	}
+	var values []string
+	err := json.Unmarshal(data, &values)
+	if err != nil {
+		return err
+	}
+	*l = values
+	return nil
+}
+
+// Scopes returns the scopes granted by both scope and scp, without duplicates.
+func (c *JwtClaim) Scopes() []string {
+	seen := make(map[string]bool)
+	var scopes []string
+	for _, scope := range append(strings.Fields(c.Scope), c.Scp...) {
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// HasScope reports whether the token grants required, either directly or
+// through a wildcard: "*" grants every scope and "orders:*" grants
+// "orders:read" and "orders:items:write".
+func (c *JwtClaim) HasScope(required string) bool {
+	for _, granted := range c.Scopes() {
+		if ScopeMatches(granted, required) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *JwtClaim) HasAllScopes(required ...string) bool {
+	for _, scope := range required {
+		if !c.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *JwtClaim) HasAnyScope(required ...string) bool {
+	for _, scope := range required {
+		if c.HasScope(scope) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *JwtClaim) HasRole(role string) bool {
+	for _, granted := range c.Roles {
+		if granted == role {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *JwtClaim) HasAnyRole(roles ...string) bool {
+	for _, role := range roles {
+		if c.HasRole(role) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeMatches reports whether the granted scope covers the required scope.
+func ScopeMatches(granted string, required string) bool {
+	if granted == required || granted == "*" {
+		return true
+	}
+	prefix, ok := strings.CutSuffix(granted, "*")
+	if !ok || !strings.HasSuffix(prefix, ":") {
+		return false
+	}
+	return strings.HasPrefix(required, prefix) && len(required) > len(prefix)
 }
@@ -0,0 +1,101 @@
+package model
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestScopeMatches(t *testing.T) {
+	tests := []struct {
+		granted  string
+		required string
+		want     bool
+	}{
+		{"orders:read", "orders:read", true},
+		{"orders:read", "orders:write", false},
+		{"*", "orders:read", true},
+		{"*", "anything", true},
+		{"orders:*", "orders:read", true},
+		{"orders:*", "orders:items:write", true},
+		{"orders:*", "orders:", false},
+		{"orders:*", "orders", false},
+		{"orders:*", "ordersx", false},
+		{"orders:*", "ordersx:read", false},
+		{"orders:*", "billing:read", false},
+		{"orders*", "orders:read", false},
+		{"orders*", "ordersx", false},
+		{"orders:read", "orders:*", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.granted+" "+tt.required, func(t *testing.T) {
+			if got := ScopeMatches(tt.granted, tt.required); got != tt.want {
+				t.Errorf("ScopeMatches(%q, %q) = %v, want %v", tt.granted, tt.required, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	claims := &JwtClaim{Scope: "profile orders:*", Scp: StringList{"billing:read"}}
+	tests := []struct {
+		required string
+		want     bool
+	}{
+		{"profile", true},
+		{"orders:read", true},
+		{"orders:items:write", true},
+		{"billing:read", true},
+		{"billing:write", false},
+		{"orders", false},
+	}
+	for _, tt := range tests {
+		if got := claims.HasScope(tt.required); got != tt.want {
+			t.Errorf("HasScope(%q) = %v, want %v", tt.required, got, tt.want)
+		}
+	}
+	if !claims.HasAllScopes("profile", "orders:read") || claims.HasAllScopes("profile", "billing:write") {
+		t.Error("HasAllScopes does not require every scope")
+	}
+	if !claims.HasAnyScope("billing:write", "billing:read") || claims.HasAnyScope("billing:write") {
+		t.Error("HasAnyScope does not require one scope")
+	}
+}
+
+func TestScpDecoding(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want []string
+	}{
+		{"string", `{"scp":"orders:read billing:read"}`, []string{"orders:read", "billing:read"}},
+		{"array", `{"scp":["orders:read","billing:read"]}`, []string{"orders:read", "billing:read"}},
+		{"empty string", `{"scp":""}`, []string{}},
+		{"missing", `{}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var claims JwtClaim
+			err := json.Unmarshal([]byte(tt.raw), &claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(claims.Scp) != len(tt.want) || (len(tt.want) > 0 && !reflect.DeepEqual([]string(claims.Scp), tt.want)) {
+				t.Errorf("scp = %q, want %q", claims.Scp, tt.want)
+			}
+		})
+	}
+	var claims JwtClaim
+	err := json.Unmarshal([]byte(`{"scp":42}`), &claims)
+	if err == nil {
+		t.Error("decoded a numeric scp")
+	}
+}
+
+func TestScopesDeduplicates(t *testing.T) {
+	claims := &JwtClaim{Scope: "orders:read profile orders:read", Scp: StringList{"profile", "billing:read"}}
+	want := []string{"orders:read", "profile", "billing:read"}
+	if got := claims.Scopes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Scopes() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+package model
+
+// KeySetInfo describes the kids of a key set and how they differ from the
+// previous key set.
+type KeySetInfo struct {
+	Kids    []string
+	Added   []string
+	Removed []string
+}
+
+func NewKeySetInfo(kids []string, previous KeySetInfo) KeySetInfo {
+	info := KeySetInfo{Kids: kids}
+	oldKids := make(map[string]bool, len(previous.Kids))
+	for _, kid := range previous.Kids {
+		oldKids[kid] = true
+	}
+	newKids := make(map[string]bool, len(kids))
+	for _, kid := range kids {
+		newKids[kid] = true
+		if !oldKids[kid] {
+			info.Added = append(info.Added, kid)
+		}
+	}
+	for _, kid := range previous.Kids {
+		if !newKids[kid] {
+			info.Removed = append(info.Removed, kid)
+		}
+	}
+	return info
+}
+
+// Changed reports whether any kid was added or removed.
+func (i KeySetInfo) Changed() bool {
+	return len(i.Added) > 0 || len(i.Removed) > 0
+}
+
+func (j *JWKS) Kids() []string {
+	kids := make([]string, 0, len(j.Keys))
+	for _, key := range j.Keys {
+		kids = append(kids, key.Kid)
+	}
+	return kids
+}
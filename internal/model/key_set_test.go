@@ -0,0 +1,42 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewKeySetInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		previous    []string
+		kids        []string
+		wantAdded   []string
+		wantRemoved []string
+	}{
+		{"first key set", nil, []string{"a"}, []string{"a"}, nil},
+		{"unchanged", []string{"a", "b"}, []string{"a", "b"}, nil, nil},
+		{"reordered", []string{"a", "b"}, []string{"b", "a"}, nil, nil},
+		{"added", []string{"a"}, []string{"b", "a"}, []string{"b"}, nil},
+		{"removed", []string{"a", "b"}, []string{"b"}, nil, []string{"a"}},
+		{"rotated", []string{"a", "b"}, []string{"c", "b"}, []string{"c"}, []string{"a"}},
+		{"emptied", []string{"a"}, nil, nil, []string{"a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := NewKeySetInfo(tt.kids, KeySetInfo{Kids: tt.previous})
+			if !reflect.DeepEqual(info.Kids, tt.kids) {
+				t.Errorf("kids = %v, want %v", info.Kids, tt.kids)
+			}
+			if !reflect.DeepEqual(info.Added, tt.wantAdded) {
+				t.Errorf("added = %v, want %v", info.Added, tt.wantAdded)
+			}
+			if !reflect.DeepEqual(info.Removed, tt.wantRemoved) {
+				t.Errorf("removed = %v, want %v", info.Removed, tt.wantRemoved)
+			}
+			wantChanged := len(tt.wantAdded) > 0 || len(tt.wantRemoved) > 0
+			if info.Changed() != wantChanged {
+				t.Errorf("Changed() = %v, want %v", info.Changed(), wantChanged)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+const (
+	KeyStatusPending  = "pending"
+	KeyStatusActive   = "active"
+	KeyStatusRetiring = "retiring"
+)
+
+// State returns the rotation state of the key. A key without a status is
+// active once it has been published for at least propagationDelay, so that
+// validators have had a chance to pick it up.
+func (k PublicKeyJWK) State(now time.Time, propagationDelay time.Duration) string {
+	switch k.Status {
+	case KeyStatusRetiring, KeyStatusPending:
+		return k.Status
+	}
+	if k.Nbf > 0 && now.Before(time.Unix(k.Nbf, 0).Add(propagationDelay)) {
+		return KeyStatusPending
+	}
+	return KeyStatusActive
+}
+
+// SigningKey returns the most recently published active key, or false if no
+// key is active yet.
+func (j *JWKS) SigningKey(now time.Time, propagationDelay time.Duration) (PublicKeyJWK, bool) {
+	var signing PublicKeyJWK
+	found := false
+	for _, key := range j.Keys {
+		if key.State(now, propagationDelay) != KeyStatusActive {
+			continue
+		}
+		if !found || key.Nbf > signing.Nbf {
+			signing = key
+			found = true
+		}
+	}
+	return signing, found
+}
@@ -0,0 +1,56 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSigningKey(t *testing.T) {
+	const delay = 10 * time.Minute
+	now := time.Unix(1_700_000_000, 0)
+	old := PublicKeyJWK{Kid: "old", Nbf: now.Add(-time.Hour).Unix()}
+	propagated := PublicKeyJWK{Kid: "propagated", Nbf: now.Add(-delay).Unix()}
+	propagating := PublicKeyJWK{Kid: "propagating", Nbf: now.Add(-delay + time.Second).Unix()}
+	tests := []struct {
+		name    string
+		keys    []PublicKeyJWK
+		wantKid string
+	}{
+		{"no keys", nil, ""},
+		{"key without nbf", []PublicKeyJWK{{Kid: "legacy"}}, "legacy"},
+		{"newest active key", []PublicKeyJWK{old, propagated}, "propagated"},
+		{"propagating key", []PublicKeyJWK{propagating, old}, "old"},
+		{"only a propagating key", []PublicKeyJWK{propagating}, ""},
+		{"pending status", []PublicKeyJWK{{Kid: "new", Nbf: propagated.Nbf, Status: KeyStatusPending}, old}, "old"},
+		{"retiring status", []PublicKeyJWK{{Kid: "retired", Nbf: propagated.Nbf, Status: KeyStatusRetiring}, old}, "old"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jwks := &JWKS{Keys: tt.keys}
+			key, ok := jwks.SigningKey(now, delay)
+			if ok != (tt.wantKid != "") || key.Kid != tt.wantKid {
+				t.Errorf("signing key = %q, %v, want %q", key.Kid, ok, tt.wantKid)
+			}
+		})
+	}
+}
+
+func TestKeyState(t *testing.T) {
+	const delay = 10 * time.Minute
+	published := time.Unix(1_700_000_000, 0)
+	key := PublicKeyJWK{Kid: "key", Nbf: published.Unix()}
+	tests := []struct {
+		at   time.Time
+		want string
+	}{
+		{published, KeyStatusPending},
+		{published.Add(delay - time.Second), KeyStatusPending},
+		{published.Add(delay), KeyStatusActive},
+		{published.Add(time.Hour), KeyStatusActive},
+	}
+	for _, tt := range tests {
+		if got := key.State(tt.at, delay); got != tt.want {
+			t.Errorf("state %v after publishing = %s, want %s", tt.at.Sub(published), got, tt.want)
+		}
+	}
+}
@@ -4,25 +4,63 @@ type PrivateKeyJWK struct {
 	Kty string `json:"kty"`
 	Kid string `json:"kid"`
 	Alg string `json:"alg"`
-	N   string `json:"n"`
-	E   string `json:"e"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
 	D   string `json:"d"`
-	P   string `json:"p"`
-	Q   string `json:"q"`
-	Dp  string `json:"dp"`
-	Dq  string `json:"dq"`
-	Qi  string `json:"qi"`
+	P   string `json:"p,omitempty"`
+	Q   string `json:"q,omitempty"`
+	Dp  string `json:"dp,omitempty"`
+	Dq  string `json:"dq,omitempty"`
+	Qi  string `json:"qi,omitempty"`
+	// Oth holds the third and subsequent primes of a multi-prime key.
+	Oth []OtherPrimeInfo `json:"oth,omitempty"`
+	Crv string           `json:"crv,omitempty"`
+	X   string           `json:"x,omitempty"`
+	Y   string           `json:"y,omitempty"`
+}
+
+type OtherPrimeInfo struct {
+	R string `json:"r"`
+	D string `json:"d"`
+	T string `json:"t"`
 }
 
 type PublicKeyJWK struct {
 	Alg string `json:"alg"`
 	Kid string `json:"kid"`
 	Kty string `json:"kty"`
-	N   string `json:"n"`
-	E   string `json:"e"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
 	Use string `json:"use"`
+	// X5c is the certificate chain of the key, leaf first, each entry
+	// standard base64 encoded DER.
+	X5c     []string `json:"x5c,omitempty"`
+	X5t     string   `json:"x5t,omitempty"`
+	X5tS256 string   `json:"x5t#S256,omitempty"`
+	// Nbf is the unix time the key was published. It is not used for
+	// verification, only to decide when the generator may sign with it.
+	Nbf    int64  `json:"nbf,omitempty"`
+	Status string `json:"status,omitempty"`
 }
 
 type JWKS struct {
 	Keys []PublicKeyJWK `json:"keys"`
 }
+
+// Public returns the public part of the private JWK.
+func (k PrivateKeyJWK) Public() PublicKeyJWK {
+	return PublicKeyJWK{
+		Alg: k.Alg,
+		Kid: k.Kid,
+		Kty: k.Kty,
+		N:   k.N,
+		E:   k.E,
+		Crv: k.Crv,
+		X:   k.X,
+		Y:   k.Y,
+		Use: "sig",
+	}
+}
@@ -0,0 +1,61 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+type x5tKey struct{}
+
+// verifyCertificate stores the thumbprint of the client certificate of a
+// mutual TLS token request in the request context.
+func (h *TokenHandler) verifyCertificate(r *http.Request) *http.Request {
+	if !h.bindCertificates || r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return r
+	}
+	x5t := model.CertificateThumbprint(r.TLS.PeerCertificates[0])
+	return r.WithContext(context.WithValue(r.Context(), x5tKey{}, x5t))
+}
+
+// bindToken binds claims to the DPoP key and the client certificate used at
+// the token endpoint, if any, and returns the token type to respond with.
+func bindToken(ctx context.Context, claims *model.JwtClaim) string {
+	tokenType := "Bearer"
+	jkt, hasJkt := ctx.Value(jktKey{}).(string)
+	x5t, hasX5t := ctx.Value(x5tKey{}).(string)
+	if !hasJkt && !hasX5t {
+		return tokenType
+	}
+	if claims.Cnf == nil {
+		claims.Cnf = &model.Confirmation{}
+	}
+	if hasJkt {
+		claims.Cnf.Jkt = jkt
+		tokenType = "DPoP"
+	}
+	if hasX5t {
+		claims.Cnf.X5tS256 = x5t
+	}
+	return tokenType
+}
+
+// checkHolder checks that the token request was made with the DPoP key and
+// the client certificate cnf binds a token to, so that a bound token is only
+// used by its holder.
+func checkHolder(ctx context.Context, cnf *model.Confirmation) error {
+	if cnf == nil {
+		return nil
+	}
+	jkt, _ := ctx.Value(jktKey{}).(string)
+	if cnf.Jkt != "" && cnf.Jkt != jkt {
+		return errors.New("token is bound to a DPoP key the request was not proven with")
+	}
+	x5t, _ := ctx.Value(x5tKey{}).(string)
+	if cnf.X5tS256 != "" && cnf.X5tS256 != x5t {
+		return errors.New("token is bound to a client certificate the request was not made with")
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/generator"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/yaml.v3"
+)
+
+var ErrClientNotFound = errors.New("client not found")
+
+// Client is a registered OAuth client. It authenticates either with a
+// secret, of which only the bcrypt hash is kept, or with private_key_jwt
+// assertions signed by a key in Jwks or published at JwksUri.
+type Client struct {
+	ID         string      `yaml:"id"`
+	SecretHash string      `yaml:"secret_hash"`
+	Jwks       *model.JWKS `yaml:"jwks"`
+	JwksUri    string      `yaml:"jwks_uri"`
+	// Scopes are the scopes the client may request. Wildcards such as
+	// "orders:*" allow every matching scope.
+	Scopes   []string `yaml:"scopes"`
+	Audience []string `yaml:"audience"`
+	// TokenTTL shortens the lifetime of the client's tokens. It cannot
+	// exceed generator.TokenTTL, which revocation and key retirement assume.
+	TokenTTL time.Duration `yaml:"token_ttl"`
+	// TokenExchange is what the client may obtain by token exchange. Clients
+	// without exchange audiences may not exchange tokens.
+	TokenExchange ExchangePolicy `yaml:"token_exchange"`
+}
+
+// HashSecret hashes a client secret for Client.SecretHash.
+func HashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// AllowsScope reports whether the client may request scope.
+func (c *Client) AllowsScope(scope string) bool {
+	for _, allowed := range c.Scopes {
+		if model.ScopeMatches(allowed, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+type ClientRegistry interface {
+	Client(ctx context.Context, id string) (*Client, error)
+}
+
+type MemoryClientRegistry struct {
+	mu      sync.RWMutex
+	clients map[string]*Client
+}
+
+// NewMemoryClientRegistry registers clients and panics if one cannot be
+// registered.
+func NewMemoryClientRegistry(clients ...*Client) *MemoryClientRegistry {
+	registry := &MemoryClientRegistry{clients: make(map[string]*Client)}
+	for _, client := range clients {
+		err := registry.Register(client)
+		if err != nil {
+			panic(err)
+		}
+	}
+	return registry
+}
+
+// Register adds client, replacing a client with the same ID. It fails for a
+// TokenTTL above generator.TokenTTL.
+func (m *MemoryClientRegistry) Register(client *Client) error {
+	err := checkTokenTTL(client)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients[client.ID] = client
+	return nil
+}
+
+func (m *MemoryClientRegistry) Client(ctx context.Context, id string) (*Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	client, ok := m.clients[id]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	return client, nil
+}
+
+// MaxTokenTTL returns the longest TokenTTL of the registered clients.
+func (m *MemoryClientRegistry) MaxTokenTTL() time.Duration {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var ttl time.Duration
+	for _, client := range m.clients {
+		ttl = max(ttl, client.TokenTTL)
+	}
+	return ttl
+}
+
+// LoadClientRegistry reads clients from a YAML or JSON file of the form
+// {"clients": [...]}.
+func LoadClientRegistry(path string) (*MemoryClientRegistry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Clients []*Client `yaml:"clients"`
+	}
+	err = yaml.Unmarshal(data, &file)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, client := range file.Clients {
+		if client.ID == "" {
+			return nil, fmt.Errorf("%s: client without id", path)
+		}
+		if client.SecretHash == "" && client.Jwks == nil && client.JwksUri == "" {
+			return nil, fmt.Errorf("%s: client %q has neither secret_hash nor jwks", path, client.ID)
+		}
+		err = checkTokenTTL(client)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return NewMemoryClientRegistry(file.Clients...), nil
+}
+
+func checkTokenTTL(client *Client) error {
+	if client.TokenTTL < 0 || client.TokenTTL > generator.TokenTTL {
+		return fmt.Errorf("client %q token_ttl must be between 0 and %v", client.ID, generator.TokenTTL)
+	}
+	return nil
+}
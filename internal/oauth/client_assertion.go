@@ -0,0 +1,205 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/replay"
+	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
+	"github.com/CalvinCYCheung/go_token_validator/internal/validator"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	AuthMethodPrivateKeyJwt = "private_key_jwt"
+	// ClientAssertionTypeJwtBearer is the client_assertion_type of RFC 7523.
+	ClientAssertionTypeJwtBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	// MaxAssertionLifetime bounds how long a client assertion may be valid,
+	// which also bounds how long its jti must be remembered.
+	MaxAssertionLifetime = 5 * time.Minute
+	// clientJwksRefresh is how often the JWKS of a client is refetched.
+	clientJwksRefresh = 5 * time.Minute
+	// clientJwksTimeout bounds a fetch of a client's jwks_uri.
+	clientJwksTimeout = 10 * time.Second
+	// clientValidatorIdle is how long the keys of a client that stopped
+	// authenticating are kept before their refresh is stopped.
+	clientValidatorIdle = time.Hour
+)
+
+// AssertionVerifier verifies RFC 7523 client assertions against the JWKS a
+// client registered, inline or by jwks_uri.
+type AssertionVerifier struct {
+	mu         sync.Mutex
+	audience   []string
+	replay     replay.Cache
+	httpClient *http.Client
+	validators map[string]*clientValidator
+	now        func() time.Time
+}
+
+// clientValidator holds the keys of a client and when they were last used,
+// so the validators of idle clients can be closed.
+type clientValidator struct {
+	keys     *validator.RsaKeyValidator
+	lastUsed time.Time
+}
+
+// NewAssertionVerifier accepts assertions whose aud contains one of
+// audience, normally the token endpoint URL and the issuer.
+func NewAssertionVerifier(audience []string, replayCache replay.Cache) *AssertionVerifier {
+	return &AssertionVerifier{
+		audience:   audience,
+		replay:     replayCache,
+		httpClient: &http.Client{Timeout: clientJwksTimeout},
+		validators: make(map[string]*clientValidator),
+		now:        time.Now,
+	}
+}
+
+// Close stops refreshing the keys of every client.
+func (v *AssertionVerifier) Close() {
+	v.mu.Lock()
+	var closing []*validator.RsaKeyValidator
+	for id, entry := range v.validators {
+		closing = append(closing, entry.keys)
+		delete(v.validators, id)
+	}
+	v.mu.Unlock()
+	closeValidators(closing)
+}
+
+// Verify checks assertion for client: signature, iss and sub equal to the
+// client ID, aud, a lifetime of at most MaxAssertionLifetime and a jti that
+// was not used before.
+func (v *AssertionVerifier) Verify(ctx context.Context, client *Client, assertion string) error {
+	keys, err := v.validator(client)
+	if err != nil {
+		return err
+	}
+	claims, err := keys.ValidateClaims(assertion)
+	if err != nil {
+		return NewError(ErrorInvalidClient, "invalid client assertion")
+	}
+	if claims.Issuer != client.ID || claims.Subject != client.ID {
+		return NewError(ErrorInvalidClient, "client assertion iss and sub must be the client_id")
+	}
+	if !v.acceptsAudience(claims.Audience) {
+		return NewError(ErrorInvalidClient, "client assertion has the wrong audience")
+	}
+	now := time.Now()
+	if claims.ExpiresAt.Time.Sub(now) > MaxAssertionLifetime {
+		return NewError(ErrorInvalidClient, "client assertion lifetime is too long")
+	}
+	if claims.IssuedAt != nil && claims.ExpiresAt.Time.Sub(claims.IssuedAt.Time) > MaxAssertionLifetime {
+		return NewError(ErrorInvalidClient, "client assertion lifetime is too long")
+	}
+	if claims.ID == "" {
+		return NewError(ErrorInvalidClient, "client assertion has no jti")
+	}
+	err = v.replay.Use(ctx, client.ID+":"+claims.ID, claims.ExpiresAt.Time)
+	if errors.Is(err, replay.ErrReplayed) {
+		return NewError(ErrorInvalidClient, "client assertion was already used")
+	}
+	return err
+}
+
+func (v *AssertionVerifier) acceptsAudience(audience jwt.ClaimStrings) bool {
+	for _, accepted := range v.audience {
+		if containsString(audience, accepted) {
+			return true
+		}
+	}
+	return false
+}
+
+// validator returns the validator holding the keys of client, created on
+// first use. The first fetch of a jwks_uri happens without holding the lock,
+// so a slow client cannot stall the others.
+func (v *AssertionVerifier) validator(client *Client) (*validator.RsaKeyValidator, error) {
+	keys, ok := v.cachedValidator(client.ID)
+	if ok {
+		return keys, nil
+	}
+	fetch := clientJwksFetch(client, v.httpClient)
+	if fetch == nil {
+		return nil, NewError(ErrorInvalidClient, "client has no registered keys")
+	}
+	// NewRsaKeyValidator panics when its first fetch fails, so fetch here
+	// and hand it the result.
+	jwks, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	first := true
+	keys = validator.NewRsaKeyValidator(clientJwksRefresh, func() (*model.JWKS, error) {
+		if first {
+			first = false
+			return jwks, nil
+		}
+		return fetch()
+	})
+	v.mu.Lock()
+	// Another request may have created the validator meanwhile.
+	if entry, ok := v.validators[client.ID]; ok {
+		entry.lastUsed = v.now()
+		v.mu.Unlock()
+		keys.Close()
+		return entry.keys, nil
+	}
+	v.validators[client.ID] = &clientValidator{keys: keys, lastUsed: v.now()}
+	v.mu.Unlock()
+	return keys, nil
+}
+
+// cachedValidator returns the validator of clientID, if any, and closes the
+// validators of clients idle for clientValidatorIdle.
+func (v *AssertionVerifier) cachedValidator(clientID string) (*validator.RsaKeyValidator, bool) {
+	v.mu.Lock()
+	now := v.now()
+	var idle []*validator.RsaKeyValidator
+	for id, entry := range v.validators {
+		if id != clientID && now.Sub(entry.lastUsed) > clientValidatorIdle {
+			idle = append(idle, entry.keys)
+			delete(v.validators, id)
+		}
+	}
+	entry, ok := v.validators[clientID]
+	if ok {
+		entry.lastUsed = now
+	}
+	v.mu.Unlock()
+	// Closing waits for a refresh in flight, so it happens without the lock.
+	closeValidators(idle)
+	if !ok {
+		return nil, false
+	}
+	return entry.keys, true
+}
+
+func closeValidators(validators []*validator.RsaKeyValidator) {
+	for _, keys := range validators {
+		keys.Close()
+	}
+}
+
+func clientJwksFetch(client *Client, httpClient *http.Client) func() (*model.JWKS, error) {
+	if client.JwksUri != "" {
+		return validator.NewHttpJwksFetch(client.JwksUri, httpClient)
+	}
+	if client.Jwks == nil {
+		return nil
+	}
+	jwks := client.Jwks
+	fetched := false
+	return func() (*model.JWKS, error) {
+		if fetched {
+			return nil, storage.ErrNotModified
+		}
+		fetched = true
+		return jwks, nil
+	}
+}
@@ -0,0 +1,115 @@
+package oauth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/replay"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testTokenEndpoint = "https://issuer.example/token"
+
+// newAssertionClient returns a client registered with an inline JWKS and a
+// function that signs assertions for it.
+func newAssertionClient(t *testing.T, id string) (*Client, func() string) {
+	t.Helper()
+	key, err := converter.GenerateRsaKey(converter.MinRsaKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := converter.PublicKeyToJwkConverter{}.Convert(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk.Kid = id
+	client := &Client{ID: id, Jwks: &model.JWKS{Keys: []model.PublicKeyJWK{jwk}}}
+	jti := 0
+	sign := func() string {
+		jti++
+		now := time.Now()
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+			Issuer:    id,
+			Subject:   id,
+			Audience:  jwt.ClaimStrings{testTokenEndpoint},
+			ID:        fmt.Sprint(id, "-", jti),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		})
+		token.Header["kid"] = id
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	return client, sign
+}
+
+func TestAssertionVerifierFetchesJwksUriWithoutBlockingOthers(t *testing.T) {
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	verifier := NewAssertionVerifier([]string{testTokenEndpoint}, replay.NewMemoryCache())
+	defer verifier.Close()
+	slowClient := &Client{ID: "slow", JwksUri: slow.URL}
+	go verifier.Verify(context.Background(), slowClient, "assertion")
+
+	client, sign := newAssertionClient(t, "fast")
+	done := make(chan error, 1)
+	go func() {
+		// Give the slow fetch time to start first.
+		time.Sleep(50 * time.Millisecond)
+		done <- verifier.Verify(context.Background(), client, sign())
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("verifying a client waited for another client's jwks_uri")
+	}
+}
+
+func TestAssertionVerifierClosesIdleValidators(t *testing.T) {
+	verifier := NewAssertionVerifier([]string{testTokenEndpoint}, replay.NewMemoryCache())
+	defer verifier.Close()
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	idle, signIdle := newAssertionClient(t, "idle")
+	active, signActive := newAssertionClient(t, "active")
+	for _, verify := range []struct {
+		client *Client
+		sign   func() string
+	}{{idle, signIdle}, {active, signActive}} {
+		err := verifier.Verify(context.Background(), verify.client, verify.sign())
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	now = now.Add(clientValidatorIdle + time.Minute)
+	err := verifier.Verify(context.Background(), active, signActive())
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.mu.Lock()
+	_, idleKept := verifier.validators["idle"]
+	_, activeKept := verifier.validators["active"]
+	verifier.mu.Unlock()
+	if idleKept || !activeKept {
+		t.Errorf("idle kept = %v, active kept = %v, want only the active client", idleKept, activeKept)
+	}
+}
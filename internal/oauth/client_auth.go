@@ -0,0 +1,137 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Token endpoint client authentication methods.
+const (
+	AuthMethodClientSecretBasic = "client_secret_basic"
+	AuthMethodClientSecretPost  = "client_secret_post"
+)
+
+// ClientAuthenticator authenticates clients at the token, introspection and
+// revocation endpoints.
+type ClientAuthenticator struct {
+	registry ClientRegistry
+	verifier *AssertionVerifier
+	// dummyHash is compared against for unknown clients, so that response
+	// times do not reveal which client IDs exist.
+	dummyHash []byte
+}
+
+// NewClientAuthenticator authenticates clients of registry by secret, and
+// by private_key_jwt unless verifier is nil.
+func NewClientAuthenticator(registry ClientRegistry, verifier *AssertionVerifier) *ClientAuthenticator {
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	if err != nil {
+		panic(err)
+	}
+	return &ClientAuthenticator{
+		registry:  registry,
+		verifier:  verifier,
+		dummyHash: dummyHash,
+	}
+}
+
+// Methods returns the supported authentication methods.
+func (a *ClientAuthenticator) Methods() []string {
+	methods := []string{AuthMethodClientSecretBasic, AuthMethodClientSecretPost}
+	if a.verifier != nil {
+		methods = append(methods, AuthMethodPrivateKeyJwt)
+	}
+	return methods
+}
+
+// Authenticate returns the client that sent r. The form must already be
+// parsed. Failures are returned as invalid_client or invalid_request errors.
+func (a *ClientAuthenticator) Authenticate(r *http.Request) (*Client, error) {
+	basicID, basicSecret, hasBasic := r.BasicAuth()
+	postID := r.PostForm.Get("client_id")
+	postSecret := r.PostForm.Get("client_secret")
+	assertion := r.PostForm.Get("client_assertion")
+	methods := 0
+	for _, used := range []bool{hasBasic, postSecret != "", assertion != ""} {
+		if used {
+			methods++
+		}
+	}
+	switch {
+	case methods > 1:
+		return nil, NewError(ErrorInvalidRequest, "multiple client authentication methods")
+	case assertion != "":
+		return a.authenticateAssertion(r, postID, assertion)
+	case hasBasic:
+		// RFC 6749 section 2.3.1 form-encodes the credentials.
+		id, err := url.QueryUnescape(basicID)
+		if err != nil {
+			return nil, NewError(ErrorInvalidClient, "malformed client credentials")
+		}
+		secret, err := url.QueryUnescape(basicSecret)
+		if err != nil {
+			return nil, NewError(ErrorInvalidClient, "malformed client credentials")
+		}
+		if postID != "" && postID != id {
+			return nil, NewError(ErrorInvalidRequest, "client_id does not match the authorization header")
+		}
+		return a.authenticateSecret(r, id, secret)
+	case postSecret != "":
+		return a.authenticateSecret(r, postID, postSecret)
+	}
+	return nil, NewError(ErrorInvalidClient, "client authentication required")
+}
+
+// authenticateAssertion authenticates a private_key_jwt client. The client is
+// identified by the assertion's sub, which must match client_id if sent.
+func (a *ClientAuthenticator) authenticateAssertion(r *http.Request, id string, assertion string) (*Client, error) {
+	if a.verifier == nil {
+		return nil, NewError(ErrorInvalidClient, "private_key_jwt is not supported")
+	}
+	if r.PostForm.Get("client_assertion_type") != ClientAssertionTypeJwtBearer {
+		return nil, NewError(ErrorInvalidRequest, "unsupported client_assertion_type")
+	}
+	unverified := jwt.RegisteredClaims{}
+	_, _, err := jwt.NewParser().ParseUnverified(assertion, &unverified)
+	if err != nil {
+		return nil, NewError(ErrorInvalidClient, "malformed client assertion")
+	}
+	if id != "" && id != unverified.Subject {
+		return nil, NewError(ErrorInvalidClient, "client_id does not match the client assertion")
+	}
+	client, err := a.registry.Client(r.Context(), unverified.Subject)
+	if errors.Is(err, ErrClientNotFound) {
+		return nil, NewError(ErrorInvalidClient, "client authentication failed")
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = a.verifier.Verify(r.Context(), client, assertion)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func (a *ClientAuthenticator) authenticateSecret(r *http.Request, id string, secret string) (*Client, error) {
+	client, err := a.registry.Client(r.Context(), id)
+	if errors.Is(err, ErrClientNotFound) {
+		bcrypt.CompareHashAndPassword(a.dummyHash, []byte(secret))
+		return nil, NewError(ErrorInvalidClient, "client authentication failed")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if client.SecretHash == "" {
+		return nil, NewError(ErrorInvalidClient, "client does not use secret authentication")
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(client.SecretHash), []byte(secret))
+	if err != nil {
+		return nil, NewError(ErrorInvalidClient, "client authentication failed")
+	}
+	return client, nil
+}
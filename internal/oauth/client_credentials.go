@@ -0,0 +1,67 @@
+package oauth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const GrantTypeClientCredentials = "client_credentials"
+
+type ClaimsGenerator interface {
+	GenerateClaims(claims *model.JwtClaim) (string, error)
+}
+
+// ClientCredentialsGrant issues tokens to authenticated clients on their own
+// behalf, as in RFC 6749 section 4.4. The token subject is the client ID.
+func ClientCredentialsGrant(generator ClaimsGenerator, authenticator *ClientAuthenticator) Grant {
+	return GrantFunc(func(r *http.Request) (*TokenResponse, error) {
+		client, err := authenticator.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		scopes, err := grantedScopes(client, r.PostForm.Get("scope"))
+		if err != nil {
+			return nil, err
+		}
+		claims := &model.JwtClaim{
+			Scope:    strings.Join(scopes, " "),
+			ClientID: client.ID,
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:  client.ID,
+				Audience: client.Audience,
+			},
+		}
+		if client.TokenTTL > 0 {
+			now := time.Now()
+			claims.IssuedAt = jwt.NewNumericDate(now)
+			claims.ExpiresAt = jwt.NewNumericDate(now.Add(client.TokenTTL))
+		}
+		tokenType := bindToken(r.Context(), claims)
+		accessToken, err := generator.GenerateClaims(claims)
+		if err != nil {
+			return nil, err
+		}
+		response := NewTokenResponse(accessToken, claims.ExpiresAt.Time, scopes)
+		response.TokenType = tokenType
+		return response, nil
+	})
+}
+
+// grantedScopes checks the requested scopes against the client's. Without a
+// scope parameter the client gets every scope it is registered for.
+func grantedScopes(client *Client, scope string) ([]string, error) {
+	requested := strings.Fields(scope)
+	if len(requested) == 0 {
+		return client.Scopes, nil
+	}
+	for _, scope := range requested {
+		if !client.AllowsScope(scope) {
+			return nil, NewError(ErrorInvalidScope, "scope "+scope+" is not allowed for this client")
+		}
+	}
+	return requested, nil
+}
@@ -0,0 +1,53 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadClientRegistryTokenTTL(t *testing.T) {
+	tests := []struct {
+		ttl     string
+		wantErr bool
+	}{
+		{ttl: "5m"},
+		{ttl: "15m"},
+		{ttl: "16m", wantErr: true},
+		{ttl: "720h", wantErr: true},
+		{ttl: "-1m", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ttl, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "clients.yaml")
+			content := "clients:\n  - id: orders\n    secret_hash: hash\n    token_ttl: " + tt.ttl + "\n"
+			err := os.WriteFile(path, []byte(content), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = LoadClientRegistry(path)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMemoryClientRegistryRegisterTokenTTL(t *testing.T) {
+	registry := NewMemoryClientRegistry()
+	err := registry.Register(&Client{ID: "orders", TokenTTL: 5 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = registry.Register(&Client{ID: "billing", TokenTTL: 720 * time.Hour})
+	if err == nil {
+		t.Fatal("registered a client whose token_ttl exceeds generator.TokenTTL")
+	}
+	_, err = registry.Client(context.Background(), "billing")
+	if !errors.Is(err, ErrClientNotFound) {
+		t.Errorf("error = %v, want ErrClientNotFound", err)
+	}
+}
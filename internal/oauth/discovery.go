@@ -0,0 +1,61 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// DiscoveryPath is where the provider metadata is served.
+const DiscoveryPath = "/.well-known/openid-configuration"
+
+// Metadata is the provider metadata of RFC 8414 and OpenID Connect
+// Discovery.
+type Metadata struct {
+	Issuer                            string   `json:"issuer"`
+	JwksUri                           string   `json:"jwks_uri"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	IntrospectionEndpoint             string   `json:"introspection_endpoint,omitempty"`
+	RevocationEndpoint                string   `json:"revocation_endpoint,omitempty"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IdTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	// IntrospectionEndpointAuthMethodsSupported is defined by RFC 8414.
+	IntrospectionEndpointAuthMethodsSupported []string `json:"introspection_endpoint_auth_methods_supported,omitempty"`
+	// DPoPSigningAlgValuesSupported is defined by RFC 9449 section 5.1.
+	DPoPSigningAlgValuesSupported []string `json:"dpop_signing_alg_values_supported,omitempty"`
+	// TlsClientCertificateBoundAccessTokens is defined by RFC 8705 section
+	// 3.3.
+	TlsClientCertificateBoundAccessTokens bool `json:"tls_client_certificate_bound_access_tokens,omitempty"`
+}
+
+// NewMetadata returns the metadata of a server at issuer with the endpoints
+// at their conventional paths.
+func NewMetadata(issuer string, grantTypes []string) Metadata {
+	base := strings.TrimSuffix(issuer, "/")
+	return Metadata{
+		Issuer:                           issuer,
+		JwksUri:                          base + "/.well-known/jwks.json",
+		TokenEndpoint:                    base + "/token",
+		IntrospectionEndpoint:            base + "/introspect",
+		RevocationEndpoint:               base + "/revoke",
+		GrantTypesSupported:              grantTypes,
+		ResponseTypesSupported:           []string{"token"},
+		SubjectTypesSupported:            []string{"public"},
+		IdTokenSigningAlgValuesSupported: []string{"RS256"},
+	}
+}
+
+func NewDiscoveryHandler(metadata Metadata) http.Handler {
+	body, err := json.Marshal(metadata)
+	if err != nil {
+		panic(err)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=3600")
+		w.Write(body)
+	})
+}
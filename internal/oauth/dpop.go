@@ -0,0 +1,26 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/dpop"
+)
+
+// ErrorInvalidDPoPProof is defined by RFC 9449 section 5.
+const ErrorInvalidDPoPProof = "invalid_dpop_proof"
+
+type jktKey struct{}
+
+// verifyDPoP verifies the DPoP proof sent to the token endpoint, if any, and
+// stores its key thumbprint in the request context.
+func (h *TokenHandler) verifyDPoP(r *http.Request) (*http.Request, error) {
+	if h.dpop == nil || len(r.Header.Values(dpop.Header)) == 0 {
+		return r, nil
+	}
+	jkt, err := h.dpop.VerifyRequest(r, h.dpopBaseUrl, "")
+	if err != nil {
+		return nil, NewError(ErrorInvalidDPoPProof, err.Error())
+	}
+	return r.WithContext(context.WithValue(r.Context(), jktKey{}, jkt)), nil
+}
@@ -0,0 +1,108 @@
+package oauth
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type ClaimsValidator interface {
+	ValidateClaims(token string) (*model.JwtClaim, error)
+}
+
+// IntrospectionResponse is an RFC 7662 introspection response. Inactive
+// tokens only carry active.
+type IntrospectionResponse struct {
+	Active    bool                `json:"active"`
+	Scope     string              `json:"scope,omitempty"`
+	ClientID  string              `json:"client_id,omitempty"`
+	TokenType string              `json:"token_type,omitempty"`
+	Exp       int64               `json:"exp,omitempty"`
+	Iat       int64               `json:"iat,omitempty"`
+	Nbf       int64               `json:"nbf,omitempty"`
+	Sub       string              `json:"sub,omitempty"`
+	Aud       jwt.ClaimStrings    `json:"aud,omitempty"`
+	Iss       string              `json:"iss,omitempty"`
+	Jti       string              `json:"jti,omitempty"`
+	Cnf       *model.Confirmation `json:"cnf,omitempty"`
+	// Roles and Act are the token's roles and RFC 8693 act claims, so
+	// introspecting services can authorize as if they validated the JWT.
+	Roles model.StringList `json:"roles,omitempty"`
+	Act   *model.Actor     `json:"act,omitempty"`
+}
+
+// NewIntrospectionResponse describes an active token with claims.
+func NewIntrospectionResponse(claims *model.JwtClaim) *IntrospectionResponse {
+	response := &IntrospectionResponse{
+		Active:    true,
+		Scope:     strings.Join(claims.Scopes(), " "),
+		ClientID:  claims.ClientID,
+		TokenType: "Bearer",
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+		Cnf:       claims.Cnf,
+		Roles:     claims.Roles,
+		Act:       claims.Act,
+	}
+	if claims.Cnf != nil && claims.Cnf.Jkt != "" {
+		response.TokenType = "DPoP"
+	}
+	if claims.ExpiresAt != nil {
+		response.Exp = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.Iat = claims.IssuedAt.Unix()
+	}
+	if claims.NotBefore != nil {
+		response.Nbf = claims.NotBefore.Unix()
+	}
+	return response
+}
+
+// IntrospectionHandler is an RFC 7662 introspection endpoint for access
+// tokens. Callers must authenticate as a registered client. Tokens that fail
+// validation, including revoked ones, are inactive.
+type IntrospectionHandler struct {
+	validator     ClaimsValidator
+	authenticator *ClientAuthenticator
+}
+
+func NewIntrospectionHandler(validator ClaimsValidator, authenticator *ClientAuthenticator) *IntrospectionHandler {
+	return &IntrospectionHandler{
+		validator:     validator,
+		authenticator: authenticator,
+	}
+}
+
+func (h *IntrospectionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		WriteError(w, &Error{Code: ErrorInvalidRequest, Description: "introspection requests must use POST", Status: http.StatusMethodNotAllowed})
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		WriteError(w, NewError(ErrorInvalidRequest, "malformed form body"))
+		return
+	}
+	_, err = h.authenticator.Authenticate(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		WriteError(w, NewError(ErrorInvalidRequest, "missing token"))
+		return
+	}
+	claims, err := h.validator.ValidateClaims(token)
+	if err != nil {
+		writeJson(w, http.StatusOK, &IntrospectionResponse{Active: false})
+		return
+	}
+	writeJson(w, http.StatusOK, NewIntrospectionResponse(claims))
+}
@@ -0,0 +1,180 @@
+package oauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrTokenInactive = errors.New("token is not active")
+
+// DefaultIntrospectionTimeout bounds an introspection request when the
+// caller's context has no deadline.
+const DefaultIntrospectionTimeout = 5 * time.Second
+
+// IntrospectionValidator validates tokens by asking an RFC 7662 introspection
+// endpoint, for services that cannot or prefer not to validate JWTs locally.
+// Responses are cached for cacheTTL, and never past the token's exp, so a
+// revocation takes up to cacheTTL to be seen.
+type IntrospectionValidator struct {
+	mu               sync.Mutex
+	introspectionUrl string
+	clientID         string
+	clientSecret     string
+	cacheTTL         time.Duration
+	httpClient       *http.Client
+	cache            map[[sha256.Size]byte]cachedIntrospection
+	now              func() time.Time
+}
+
+type cachedIntrospection struct {
+	claims *model.JwtClaim
+	until  time.Time
+}
+
+func NewIntrospectionValidator(introspectionUrl string, clientID string, clientSecret string, cacheTTL time.Duration) *IntrospectionValidator {
+	return &IntrospectionValidator{
+		introspectionUrl: introspectionUrl,
+		clientID:         clientID,
+		clientSecret:     clientSecret,
+		cacheTTL:         cacheTTL,
+		httpClient:       &http.Client{Timeout: DefaultIntrospectionTimeout},
+		cache:            make(map[[sha256.Size]byte]cachedIntrospection),
+		now:              time.Now,
+	}
+}
+
+// SetHTTPClient sets the client of introspection requests, for example to
+// change the timeout or authenticate with mutual TLS.
+func (v *IntrospectionValidator) SetHTTPClient(client *http.Client) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.httpClient = client
+}
+
+func (v *IntrospectionValidator) Validate(token string) (bool, error) {
+	_, err := v.ValidateClaims(token)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ValidateClaims returns the claims of an active token and ErrTokenInactive
+// otherwise.
+func (v *IntrospectionValidator) ValidateClaims(token string) (*model.JwtClaim, error) {
+	return v.ValidateClaimsContext(context.Background(), token)
+}
+
+// ValidateClaimsContext is ValidateClaims with the introspection request
+// bound to ctx.
+func (v *IntrospectionValidator) ValidateClaimsContext(ctx context.Context, token string) (*model.JwtClaim, error) {
+	// Tokens are cached by hash so the cache does not hold usable tokens.
+	key := sha256.Sum256([]byte(token))
+	now := v.now()
+	v.mu.Lock()
+	cached, ok := v.cache[key]
+	client := v.httpClient
+	v.mu.Unlock()
+	if ok && now.Before(cached.until) {
+		if cached.claims == nil {
+			return nil, ErrTokenInactive
+		}
+		return cached.claims, nil
+	}
+	claims, err := v.introspect(ctx, client, token)
+	if err != nil {
+		return nil, err
+	}
+	until := now.Add(v.cacheTTL)
+	if claims != nil && claims.ExpiresAt != nil && claims.ExpiresAt.Time.Before(until) {
+		until = claims.ExpiresAt.Time
+	}
+	v.mu.Lock()
+	for cachedKey, entry := range v.cache {
+		if !now.Before(entry.until) {
+			delete(v.cache, cachedKey)
+		}
+	}
+	v.cache[key] = cachedIntrospection{claims: claims, until: until}
+	v.mu.Unlock()
+	if claims == nil {
+		return nil, ErrTokenInactive
+	}
+	return claims, nil
+}
+
+// introspect returns the claims of an active token and nil for an inactive
+// one.
+func (v *IntrospectionValidator) introspect(ctx context.Context, client *http.Client, token string) (*model.JwtClaim, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.introspectionUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(v.clientID), url.QueryEscape(v.clientSecret))
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection endpoint returned %s", res.Status)
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	var response IntrospectionResponse
+	err = json.Unmarshal(body, &response)
+	if err != nil {
+		return nil, err
+	}
+	if !response.Active {
+		return nil, nil
+	}
+	if response.Exp != 0 && !v.now().Before(time.Unix(response.Exp, 0)) {
+		return nil, nil
+	}
+	claims := &model.JwtClaim{
+		Scope:    response.Scope,
+		Roles:    response.Roles,
+		ClientID: response.ClientID,
+		Act:      response.Act,
+		Cnf:      response.Cnf,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:  response.Sub,
+			Audience: response.Aud,
+			Issuer:   response.Iss,
+			ID:       response.Jti,
+		},
+	}
+	if response.Exp != 0 {
+		claims.ExpiresAt = jwt.NewNumericDate(time.Unix(response.Exp, 0))
+	}
+	if response.Iat != 0 {
+		claims.IssuedAt = jwt.NewNumericDate(time.Unix(response.Iat, 0))
+	}
+	if response.Nbf != 0 {
+		claims.NotBefore = jwt.NewNumericDate(time.Unix(response.Nbf, 0))
+	}
+	// Raw keeps extension members of the response for policy evaluation.
+	err = json.Unmarshal(body, &claims.Raw)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
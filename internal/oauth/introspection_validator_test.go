@@ -0,0 +1,58 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestIntrospectionKeepsRolesAndAct(t *testing.T) {
+	claims := &model.JwtClaim{
+		Scope: "orders:read",
+		Roles: model.StringList{"admin"},
+		Act:   &model.Actor{Subject: "gateway", Act: &model.Actor{Subject: "frontend"}},
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   "user-1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJson(w, http.StatusOK, NewIntrospectionResponse(claims))
+	}))
+	defer ts.Close()
+
+	validator := NewIntrospectionValidator(ts.URL, "orders", "secret", time.Minute)
+	got, err := validator.ValidateClaims("token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.HasRole("admin") {
+		t.Errorf("roles = %v, want admin", got.Roles)
+	}
+	if got.Act == nil || got.Act.Subject != "gateway" || got.Act.Act == nil || got.Act.Act.Subject != "frontend" {
+		t.Errorf("act = %+v, want gateway acting for frontend", got.Act)
+	}
+}
+
+func TestIntrospectionUsesCallerContext(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	validator := NewIntrospectionValidator(ts.URL, "orders", "secret", time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := validator.ValidateClaimsContext(ctx, "token")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want context.DeadlineExceeded", err)
+	}
+}
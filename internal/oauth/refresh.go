@@ -0,0 +1,43 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/refresh"
+)
+
+const GrantTypeRefreshToken = "refresh_token"
+
+// RefreshTokenGrant exchanges a refresh token for a new token pair, as in
+// RFC 6749 section 6. Refresh tokens bound to a DPoP key need a proof of
+// that key, as RFC 9449 section 5 requires.
+func RefreshTokenGrant(issuer *refresh.Issuer) Grant {
+	return GrantFunc(func(r *http.Request) (*TokenResponse, error) {
+		refreshToken := r.PostForm.Get("refresh_token")
+		if refreshToken == "" {
+			return nil, NewError(ErrorInvalidRequest, "missing refresh_token")
+		}
+		tokenType := "Bearer"
+		jkt, _ := r.Context().Value(jktKey{}).(string)
+		pair, err := issuer.RefreshClaims(r.Context(), refreshToken, jkt, func(claims *model.JwtClaim) {
+			tokenType = bindToken(r.Context(), claims)
+		})
+		if errors.Is(err, refresh.ErrInvalidGrant) {
+			return nil, NewError(ErrorInvalidGrant, refresh.ErrInvalidGrant.Error())
+		}
+		if err != nil {
+			return nil, err
+		}
+		response := pairResponse(pair)
+		response.TokenType = tokenType
+		return response, nil
+	})
+}
+
+func pairResponse(pair *refresh.TokenPair) *TokenResponse {
+	response := NewTokenResponse(pair.AccessToken, pair.ExpiresAt, pair.Scopes)
+	response.RefreshToken = pair.RefreshToken
+	return response
+}
@@ -0,0 +1,136 @@
+package oauth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/dpop"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/refresh"
+	"github.com/CalvinCYCheung/go_token_validator/internal/replay"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// recordingGenerator remembers the claims of the last token it minted.
+type recordingGenerator struct {
+	claims *model.JwtClaim
+}
+
+func (g *recordingGenerator) GenerateClaims(claims *model.JwtClaim) (string, error) {
+	claims.ID = "jti"
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	g.claims = claims
+	return "access", nil
+}
+
+const testBaseUrl = "https://issuer.example"
+
+func newDPoPKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// newRefreshHandler returns a token endpoint for the refresh grant of issuer
+// that verifies DPoP proofs.
+func newRefreshHandler(issuer *refresh.Issuer) *TokenHandler {
+	handler := NewTokenHandler()
+	handler.SetDPoPVerifier(dpop.NewVerifier(time.Minute, replay.NewMemoryCache()), testBaseUrl)
+	handler.Handle(GrantTypeRefreshToken, RefreshTokenGrant(issuer))
+	return handler
+}
+
+// refreshWith sends a refresh request proven with key, or without a proof
+// when key is nil.
+func refreshWith(t *testing.T, handler *TokenHandler, refreshToken string, key *ecdsa.PrivateKey) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"grant_type": {GrantTypeRefreshToken}, "refresh_token": {refreshToken}}
+	r := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if key != nil {
+		proof, err := dpop.NewProof(key, http.MethodPost, testBaseUrl+"/token", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set(dpop.Header, proof)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestRefreshTokenGrantBindsToDPoPKey(t *testing.T) {
+	key := newDPoPKey(t)
+	jkt, err := dpop.Thumbprint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	generator := &recordingGenerator{}
+	issuer := refresh.NewIssuer(generator, refresh.NewMemoryStore(), time.Hour)
+	pair, err := issuer.IssueBound(context.Background(), refresh.Binding{Jkt: jkt}, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := refreshWith(t, newRefreshHandler(issuer), pair.RefreshToken, key)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body)
+	}
+	var response TokenResponse
+	err = json.NewDecoder(w.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.TokenType != "DPoP" {
+		t.Errorf("token_type = %q, want DPoP", response.TokenType)
+	}
+	if generator.claims.Cnf == nil || generator.claims.Cnf.Jkt != jkt {
+		t.Errorf("cnf = %+v, want jkt %s", generator.claims.Cnf, jkt)
+	}
+}
+
+func TestRefreshTokenGrantRejectsOtherProofKeys(t *testing.T) {
+	key := newDPoPKey(t)
+	jkt, err := dpop.Thumbprint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		key  *ecdsa.PrivateKey
+	}{
+		{"without a proof", nil},
+		{"with another key", newDPoPKey(t)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issuer := refresh.NewIssuer(&recordingGenerator{}, refresh.NewMemoryStore(), time.Hour)
+			pair, err := issuer.IssueBound(context.Background(), refresh.Binding{Jkt: jkt}, "user-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := newRefreshHandler(issuer)
+			w := refreshWith(t, handler, pair.RefreshToken, tt.key)
+			if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), ErrorInvalidGrant) {
+				t.Fatalf("status = %d: %s, want invalid_grant", w.Code, w.Body)
+			}
+			// The rejected attempt does not use up the token of its owner.
+			w = refreshWith(t, handler, pair.RefreshToken, key)
+			if w.Code != http.StatusOK {
+				t.Errorf("refresh by the key holder: status = %d: %s", w.Code, w.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/refresh"
+	"github.com/CalvinCYCheung/go_token_validator/internal/revocation"
+)
+
+// RevocationHandler is an RFC 7009 revocation endpoint. Callers must
+// authenticate as a registered client and may only revoke tokens issued to
+// them. Access tokens are added to the revocation store and refresh tokens
+// revoke their family.
+type RevocationHandler struct {
+	validator     ClaimsValidator
+	revocations   revocation.RevocationStore
+	issuer        *refresh.Issuer
+	authenticator *ClientAuthenticator
+}
+
+// NewRevocationHandler returns a revocation endpoint. issuer may be nil when
+// the server issues no refresh tokens.
+func NewRevocationHandler(validator ClaimsValidator, revocations revocation.RevocationStore, issuer *refresh.Issuer, authenticator *ClientAuthenticator) *RevocationHandler {
+	return &RevocationHandler{
+		validator:     validator,
+		revocations:   revocations,
+		issuer:        issuer,
+		authenticator: authenticator,
+	}
+}
+
+// ServeHTTP answers 200 for unknown and invalid tokens too, as RFC 7009
+// section 2.2 requires.
+func (h *RevocationHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		WriteError(w, &Error{Code: ErrorInvalidRequest, Description: "revocation requests must use POST", Status: http.StatusMethodNotAllowed})
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		WriteError(w, NewError(ErrorInvalidRequest, "malformed form body"))
+		return
+	}
+	client, err := h.authenticator.Authenticate(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		WriteError(w, NewError(ErrorInvalidRequest, "missing token"))
+		return
+	}
+	// Access tokens are JWTs while refresh tokens are opaque, so the token
+	// itself tells its type and token_type_hint is not needed.
+	if strings.Count(token, ".") == 2 {
+		err = h.revokeAccessToken(r, client, token)
+	} else if h.issuer != nil {
+		err = h.issuer.RevokeFamily(r.Context(), token, client.ID)
+	}
+	if errors.Is(err, refresh.ErrClientMismatch) {
+		err = NewError(ErrorUnauthorizedClient, "token was not issued to this client")
+	}
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *RevocationHandler) revokeAccessToken(r *http.Request, client *Client, token string) error {
+	claims, err := h.validator.ValidateClaims(token)
+	if err != nil {
+		// Expired, revoked and forged tokens need no revocation.
+		return nil
+	}
+	if claims.ClientID != client.ID {
+		return NewError(ErrorUnauthorizedClient, "token was not issued to this client")
+	}
+	return revocation.RevokeClaims(r.Context(), h.revocations, claims)
+}
@@ -0,0 +1,66 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/refresh"
+	"github.com/CalvinCYCheung/go_token_validator/internal/revocation"
+)
+
+func TestRevocationHandlerRefreshTokenOwner(t *testing.T) {
+	var clients []*Client
+	for _, id := range []string{"orders", "billing"} {
+		hash, err := HashSecret(id + "-secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		clients = append(clients, &Client{ID: id, SecretHash: hash})
+	}
+	authenticator := NewClientAuthenticator(NewMemoryClientRegistry(clients...), nil)
+	generator := &recordingGenerator{}
+	issuer := refresh.NewIssuer(generator, refresh.NewMemoryStore(), time.Hour)
+	handler := NewRevocationHandler(nil, revocation.NewMemoryStore(time.Hour), issuer, authenticator)
+
+	ctx := context.Background()
+	pair, err := issuer.IssueBound(ctx, refresh.Binding{ClientID: "orders"}, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if generator.claims.ClientID != "orders" {
+		t.Errorf("access token client_id = %q, want orders", generator.claims.ClientID)
+	}
+	revoke := func(clientID string) *httptest.ResponseRecorder {
+		form := url.Values{"token": {pair.RefreshToken}}
+		r := httptest.NewRequest(http.MethodPost, "/revoke", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.SetBasicAuth(clientID, clientID+"-secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	w := revoke("billing")
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), ErrorUnauthorizedClient) {
+		t.Fatalf("revoke by another client: status = %d, body = %s, want 400 unauthorized_client", w.Code, w.Body)
+	}
+	pair, err = issuer.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("refresh after a rejected revocation: %v", err)
+	}
+
+	w = revoke("orders")
+	if w.Code != http.StatusOK {
+		t.Fatalf("revoke by the owner: status = %d, body = %s, want 200", w.Code, w.Body)
+	}
+	_, err = issuer.Refresh(ctx, pair.RefreshToken)
+	if !errors.Is(err, refresh.ErrInvalidGrant) {
+		t.Fatalf("refresh after revocation: error = %v, want ErrInvalidGrant", err)
+	}
+}
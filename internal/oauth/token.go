@@ -0,0 +1,185 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/dpop"
+)
+
+// RFC 6749 section 5.2 error codes.
+const (
+	ErrorInvalidRequest       = "invalid_request"
+	ErrorInvalidClient        = "invalid_client"
+	ErrorInvalidGrant         = "invalid_grant"
+	ErrorUnauthorizedClient   = "unauthorized_client"
+	ErrorUnsupportedGrantType = "unsupported_grant_type"
+	ErrorInvalidScope         = "invalid_scope"
+	ErrorServerError          = "server_error"
+	// ErrorInvalidTarget is defined by RFC 8693 section 2.2.2.
+	ErrorInvalidTarget = "invalid_target"
+)
+
+// Error is an OAuth error response.
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	Status      int    `json:"-"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func NewError(code string, description string) *Error {
+	status := http.StatusBadRequest
+	if code == ErrorInvalidClient {
+		status = http.StatusUnauthorized
+	}
+	return &Error{Code: code, Description: description, Status: status}
+}
+
+// TokenResponse is a successful token response as defined by RFC 6749
+// section 5.1.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	// IssuedTokenType is set for token exchange responses.
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in,omitempty"`
+	RefreshToken    string `json:"refresh_token,omitempty"`
+	Scope           string `json:"scope,omitempty"`
+}
+
+// NewTokenResponse returns a Bearer token response for a token expiring at
+// expiresAt.
+func NewTokenResponse(accessToken string, expiresAt time.Time, scopes []string) *TokenResponse {
+	return &TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(expiresAt).Round(time.Second).Seconds()),
+		Scope:       strings.Join(scopes, " "),
+	}
+}
+
+// Grant handles the token request of one grant type. Errors that are not an
+// *Error are reported as server_error.
+type Grant interface {
+	Token(r *http.Request) (*TokenResponse, error)
+}
+
+type GrantFunc func(r *http.Request) (*TokenResponse, error)
+
+func (f GrantFunc) Token(r *http.Request) (*TokenResponse, error) {
+	return f(r)
+}
+
+// TokenHandler is the token endpoint. It dispatches on the grant_type
+// parameter to the registered grants.
+type TokenHandler struct {
+	grants           map[string]Grant
+	dpop             *dpop.Verifier
+	dpopBaseUrl      string
+	bindCertificates bool
+}
+
+func NewTokenHandler() *TokenHandler {
+	return &TokenHandler{grants: make(map[string]Grant)}
+}
+
+// Handle registers grant for grantType. It is not safe to call while serving.
+func (h *TokenHandler) Handle(grantType string, grant Grant) {
+	h.grants[grantType] = grant
+}
+
+// SetDPoPVerifier binds issued tokens to the key of a DPoP proof sent with
+// the token request. baseUrl is the external scheme and host of the server
+// and must not be empty.
+func (h *TokenHandler) SetDPoPVerifier(verifier *dpop.Verifier, baseUrl string) {
+	if baseUrl == "" {
+		panic(dpop.ErrNoBaseUrl)
+	}
+	h.dpop = verifier
+	h.dpopBaseUrl = baseUrl
+}
+
+// SetCertificateBinding binds tokens requested over mutual TLS to the client
+// certificate, as in RFC 8705 section 3.
+func (h *TokenHandler) SetCertificateBinding(enabled bool) {
+	h.bindCertificates = enabled
+}
+
+// GrantTypes returns the registered grant types in sorted order.
+func (h *TokenHandler) GrantTypes() []string {
+	grantTypes := make([]string, 0, len(h.grants))
+	for grantType := range h.grants {
+		grantTypes = append(grantTypes, grantType)
+	}
+	sort.Strings(grantTypes)
+	return grantTypes
+}
+
+func (h *TokenHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", "POST")
+		WriteError(w, &Error{Code: ErrorInvalidRequest, Description: "token requests must use POST", Status: http.StatusMethodNotAllowed})
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		WriteError(w, NewError(ErrorInvalidRequest, "malformed form body"))
+		return
+	}
+	grantType := r.PostForm.Get("grant_type")
+	if grantType == "" {
+		WriteError(w, NewError(ErrorInvalidRequest, "missing grant_type"))
+		return
+	}
+	grant, ok := h.grants[grantType]
+	if !ok {
+		WriteError(w, NewError(ErrorUnsupportedGrantType, ""))
+		return
+	}
+	r, err = h.verifyDPoP(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	r = h.verifyCertificate(r)
+	response, err := grant.Token(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJson(w, http.StatusOK, response)
+}
+
+// WriteError writes err as an OAuth error response. invalid_client responses
+// carry a Basic challenge as RFC 6749 section 5.2 requires.
+func WriteError(w http.ResponseWriter, err error) {
+	var oauthErr *Error
+	if !errors.As(err, &oauthErr) {
+		fmt.Println("token endpoint error: ", err)
+		oauthErr = &Error{Code: ErrorServerError, Status: http.StatusInternalServerError}
+	}
+	if oauthErr.Status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+	}
+	writeJson(w, oauthErr.Status, oauthErr)
+}
+
+func writeJson(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,218 @@
+package oauth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// RFC 8693 grant and token type identifiers.
+const (
+	GrantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJwt           = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// DefaultExchangeTTL is the lifetime of exchanged tokens for clients without
+// a TokenTTL.
+const DefaultExchangeTTL = 5 * time.Minute
+
+// ExchangePolicy limits the tokens a client can obtain by token exchange.
+type ExchangePolicy struct {
+	// Audiences the client may request tokens for.
+	Audiences []string `yaml:"audiences"`
+	// Scopes the exchanged tokens may carry, wildcards allowed. Exchanged
+	// tokens never carry scopes the subject token lacks.
+	Scopes []string `yaml:"scopes"`
+	// RequireSubjectAudience only admits subject tokens issued to the client,
+	// that is whose aud contains the client ID.
+	RequireSubjectAudience bool `yaml:"require_subject_audience"`
+	// MaxDelegationDepth limits the length of the act chain. Zero means no
+	// limit.
+	MaxDelegationDepth int `yaml:"max_delegation_depth"`
+}
+
+func (p ExchangePolicy) allowsAudience(audience string) bool {
+	for _, allowed := range p.Audiences {
+		if allowed == audience {
+			return true
+		}
+	}
+	return false
+}
+
+func (p ExchangePolicy) allowsScope(scope string) bool {
+	for _, allowed := range p.Scopes {
+		if model.ScopeMatches(allowed, scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExchangeRequest is an RFC 8693 token exchange request.
+type ExchangeRequest struct {
+	SubjectToken     string
+	SubjectTokenType string
+	Audience         []string
+	Scopes           []string
+}
+
+// Exchanger mints tokens for a client acting on behalf of the subject of
+// another token. The new token keeps the subject, is limited to the requested
+// audience and scopes, and records the client in its act claim. Subject
+// tokens bound to a DPoP key or client certificate need a request made with
+// that key or certificate, and the new token keeps the binding.
+type Exchanger struct {
+	validator ClaimsValidator
+	generator ClaimsGenerator
+}
+
+func NewExchanger(validator ClaimsValidator, generator ClaimsGenerator) *Exchanger {
+	return &Exchanger{
+		validator: validator,
+		generator: generator,
+	}
+}
+
+func (e *Exchanger) Exchange(ctx context.Context, client *Client, request ExchangeRequest) (*TokenResponse, error) {
+	policy := client.TokenExchange
+	if len(policy.Audiences) == 0 {
+		return nil, NewError(ErrorUnauthorizedClient, "client may not exchange tokens")
+	}
+	if request.SubjectToken == "" {
+		return nil, NewError(ErrorInvalidRequest, "missing subject_token")
+	}
+	if request.SubjectTokenType != TokenTypeAccessToken && request.SubjectTokenType != TokenTypeJwt {
+		return nil, NewError(ErrorInvalidRequest, "unsupported subject_token_type")
+	}
+	if len(request.Audience) == 0 {
+		return nil, NewError(ErrorInvalidTarget, "missing audience")
+	}
+	for _, audience := range request.Audience {
+		if !policy.allowsAudience(audience) {
+			return nil, NewError(ErrorInvalidTarget, "audience "+audience+" is not allowed for this client")
+		}
+	}
+	subject, err := e.validator.ValidateClaims(request.SubjectToken)
+	if err != nil {
+		return nil, NewError(ErrorInvalidRequest, "invalid subject_token")
+	}
+	if policy.RequireSubjectAudience && !containsString(subject.Audience, client.ID) {
+		return nil, NewError(ErrorInvalidRequest, "subject_token was not issued to this client")
+	}
+	// A bound subject token may only be exchanged by its holder, and the
+	// exchanged token is bound to the same key and certificate below.
+	err = checkHolder(ctx, subject.Cnf)
+	if err != nil {
+		return nil, NewError(ErrorInvalidRequest, "subject_token: "+err.Error())
+	}
+	scopes, err := exchangedScopes(policy, subject, request.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	act := &model.Actor{Subject: client.ID, Act: subject.Act}
+	if policy.MaxDelegationDepth > 0 && act.Depth() > policy.MaxDelegationDepth {
+		return nil, NewError(ErrorInvalidRequest, "delegation chain is too long")
+	}
+	now := time.Now()
+	ttl := client.TokenTTL
+	if ttl <= 0 {
+		ttl = DefaultExchangeTTL
+	}
+	// The exchanged token never outlives the subject token.
+	expiresAt := now.Add(ttl)
+	if subject.ExpiresAt != nil && subject.ExpiresAt.Time.Before(expiresAt) {
+		expiresAt = subject.ExpiresAt.Time
+	}
+	claims := &model.JwtClaim{
+		Scope:    strings.Join(scopes, " "),
+		ClientID: client.ID,
+		Act:      act,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject.Subject,
+			Audience:  request.Audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	tokenType := bindToken(ctx, claims)
+	accessToken, err := e.generator.GenerateClaims(claims)
+	if err != nil {
+		return nil, err
+	}
+	response := NewTokenResponse(accessToken, expiresAt, scopes)
+	response.TokenType = tokenType
+	response.IssuedTokenType = TokenTypeAccessToken
+	return response, nil
+}
+
+// exchangedScopes returns the requested scopes, or when none are requested
+// the intersection of the subject token's scopes and the policy, where either
+// side may use wildcards.
+func exchangedScopes(policy ExchangePolicy, subject *model.JwtClaim, requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		var scopes []string
+		for _, scope := range subject.Scopes() {
+			if policy.allowsScope(scope) && !containsString(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		for _, scope := range policy.Scopes {
+			if subject.HasScope(scope) && !containsString(scopes, scope) {
+				scopes = append(scopes, scope)
+			}
+		}
+		return scopes, nil
+	}
+	for _, scope := range requested {
+		if !subject.HasScope(scope) {
+			return nil, NewError(ErrorInvalidScope, "subject_token does not grant scope "+scope)
+		}
+		if !policy.allowsScope(scope) {
+			return nil, NewError(ErrorInvalidScope, "scope "+scope+" is not allowed for this client")
+		}
+	}
+	return requested, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenExchangeGrant is the token endpoint grant for RFC 8693. The
+// authenticated client is the actor; actor_token is not supported.
+func TokenExchangeGrant(exchanger *Exchanger, authenticator *ClientAuthenticator) Grant {
+	return GrantFunc(func(r *http.Request) (*TokenResponse, error) {
+		client, err := authenticator.Authenticate(r)
+		if err != nil {
+			return nil, err
+		}
+		if r.PostForm.Get("actor_token") != "" {
+			return nil, NewError(ErrorInvalidRequest, "actor_token is not supported")
+		}
+		requestedType := r.PostForm.Get("requested_token_type")
+		if requestedType != "" && requestedType != TokenTypeAccessToken && requestedType != TokenTypeJwt {
+			return nil, NewError(ErrorInvalidRequest, "unsupported requested_token_type")
+		}
+		// resource is a URI form of the audience.
+		var audience []string
+		audience = append(audience, r.PostForm["audience"]...)
+		audience = append(audience, r.PostForm["resource"]...)
+		return exchanger.Exchange(r.Context(), client, ExchangeRequest{
+			SubjectToken:     r.PostForm.Get("subject_token"),
+			SubjectTokenType: r.PostForm.Get("subject_token_type"),
+			Audience:         audience,
+			Scopes:           strings.Fields(r.PostForm.Get("scope")),
+		})
+	})
+}
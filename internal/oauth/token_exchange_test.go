@@ -0,0 +1,259 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/testutil"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestExchange(t *testing.T) {
+	subjects := testutil.StubValidator{
+		"user": {
+			Scope: "orders:read orders:write profile",
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:  "user-1",
+				Audience: jwt.ClaimStrings{"gateway"},
+			},
+		},
+		"delegated": {
+			Scope: "orders:read",
+			Act:   &model.Actor{Subject: "frontend"},
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:  "user-1",
+				Audience: jwt.ClaimStrings{"frontend"},
+			},
+		},
+		"twice delegated": {
+			Scope: "orders:read",
+			Act:   &model.Actor{Subject: "edge", Act: &model.Actor{Subject: "frontend"}},
+			RegisteredClaims: jwt.RegisteredClaims{
+				Subject:  "user-1",
+				Audience: jwt.ClaimStrings{"gateway"},
+			},
+		},
+	}
+	policy := ExchangePolicy{
+		Audiences:          []string{"orders", "billing"},
+		Scopes:             []string{"orders:*", "billing:read"},
+		MaxDelegationDepth: 2,
+	}
+	tests := []struct {
+		name       string
+		policy     *ExchangePolicy
+		request    ExchangeRequest
+		wantCode   string
+		wantScopes []string
+		wantAct    *model.Actor
+	}{
+		{
+			name:       "default scopes",
+			request:    ExchangeRequest{SubjectToken: "user", Audience: []string{"orders"}},
+			wantScopes: []string{"orders:read", "orders:write"},
+			wantAct:    &model.Actor{Subject: "gateway"},
+		},
+		{
+			name:       "requested scope",
+			request:    ExchangeRequest{SubjectToken: "user", Audience: []string{"orders"}, Scopes: []string{"orders:read"}},
+			wantScopes: []string{"orders:read"},
+			wantAct:    &model.Actor{Subject: "gateway"},
+		},
+		{
+			name:     "scope the subject lacks",
+			request:  ExchangeRequest{SubjectToken: "user", Audience: []string{"billing"}, Scopes: []string{"billing:read"}},
+			wantCode: ErrorInvalidScope,
+		},
+		{
+			name:     "scope the policy lacks",
+			request:  ExchangeRequest{SubjectToken: "user", Audience: []string{"orders"}, Scopes: []string{"profile"}},
+			wantCode: ErrorInvalidScope,
+		},
+		{
+			name:     "audience the policy lacks",
+			request:  ExchangeRequest{SubjectToken: "user", Audience: []string{"orders", "admin"}},
+			wantCode: ErrorInvalidTarget,
+		},
+		{
+			name:     "missing audience",
+			request:  ExchangeRequest{SubjectToken: "user"},
+			wantCode: ErrorInvalidTarget,
+		},
+		{
+			name:     "invalid subject token",
+			request:  ExchangeRequest{SubjectToken: "forged", Audience: []string{"orders"}},
+			wantCode: ErrorInvalidRequest,
+		},
+		{
+			name:     "unsupported subject token type",
+			request:  ExchangeRequest{SubjectToken: "user", SubjectTokenType: "urn:ietf:params:oauth:token-type:saml2", Audience: []string{"orders"}},
+			wantCode: ErrorInvalidRequest,
+		},
+		{
+			name:     "client without exchange audiences",
+			policy:   &ExchangePolicy{},
+			request:  ExchangeRequest{SubjectToken: "user", Audience: []string{"orders"}},
+			wantCode: ErrorUnauthorizedClient,
+		},
+		{
+			name:       "subject token issued to the client",
+			policy:     &ExchangePolicy{Audiences: []string{"orders"}, Scopes: []string{"orders:read"}, RequireSubjectAudience: true},
+			request:    ExchangeRequest{SubjectToken: "user", Audience: []string{"orders"}},
+			wantScopes: []string{"orders:read"},
+			wantAct:    &model.Actor{Subject: "gateway"},
+		},
+		{
+			name:     "subject token issued to another client",
+			policy:   &ExchangePolicy{Audiences: []string{"orders"}, Scopes: []string{"orders:read"}, RequireSubjectAudience: true},
+			request:  ExchangeRequest{SubjectToken: "delegated", Audience: []string{"orders"}},
+			wantCode: ErrorInvalidRequest,
+		},
+		{
+			name:       "nested act chain",
+			request:    ExchangeRequest{SubjectToken: "delegated", Audience: []string{"orders"}},
+			wantScopes: []string{"orders:read"},
+			wantAct:    &model.Actor{Subject: "gateway", Act: &model.Actor{Subject: "frontend"}},
+		},
+		{
+			name:     "act chain too long",
+			request:  ExchangeRequest{SubjectToken: "twice delegated", Audience: []string{"orders"}},
+			wantCode: ErrorInvalidRequest,
+		},
+		{
+			name:       "unlimited act chain",
+			policy:     &ExchangePolicy{Audiences: []string{"orders"}, Scopes: []string{"orders:*"}},
+			request:    ExchangeRequest{SubjectToken: "twice delegated", Audience: []string{"orders"}},
+			wantScopes: []string{"orders:read"},
+			wantAct:    &model.Actor{Subject: "gateway", Act: &model.Actor{Subject: "edge", Act: &model.Actor{Subject: "frontend"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{ID: "gateway", TokenExchange: policy}
+			if tt.policy != nil {
+				client.TokenExchange = *tt.policy
+			}
+			if tt.request.SubjectTokenType == "" {
+				tt.request.SubjectTokenType = TokenTypeAccessToken
+			}
+			generator := &recordingGenerator{}
+			exchanger := NewExchanger(subjects, generator)
+			response, err := exchanger.Exchange(context.Background(), client, tt.request)
+			if tt.wantCode != "" {
+				var oauthErr *Error
+				if !errors.As(err, &oauthErr) || oauthErr.Code != tt.wantCode {
+					t.Fatalf("error = %v, want %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			claims := generator.claims
+			if !reflect.DeepEqual(claims.Scopes(), tt.wantScopes) {
+				t.Errorf("scopes = %v, want %v", claims.Scopes(), tt.wantScopes)
+			}
+			if !reflect.DeepEqual(claims.Act, tt.wantAct) {
+				t.Errorf("act = %+v, want %+v", claims.Act, tt.wantAct)
+			}
+			if claims.Subject != "user-1" || claims.ClientID != "gateway" {
+				t.Errorf("sub = %q, client_id = %q, want user-1 and gateway", claims.Subject, claims.ClientID)
+			}
+			if !reflect.DeepEqual([]string(claims.Audience), tt.request.Audience) {
+				t.Errorf("aud = %v, want %v", claims.Audience, tt.request.Audience)
+			}
+			if response.IssuedTokenType != TokenTypeAccessToken || response.TokenType != "Bearer" {
+				t.Errorf("issued_token_type = %q, token_type = %q", response.IssuedTokenType, response.TokenType)
+			}
+		})
+	}
+}
+
+func TestExchangeNeverOutlivesSubjectToken(t *testing.T) {
+	expiresAt := time.Now().Add(time.Minute)
+	subjects := testutil.StubValidator{"user": {
+		Scope:            "orders:read",
+		RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1", ExpiresAt: jwt.NewNumericDate(expiresAt)},
+	}}
+	client := &Client{ID: "gateway", TokenTTL: time.Hour, TokenExchange: ExchangePolicy{Audiences: []string{"orders"}}}
+	response, err := NewExchanger(subjects, &recordingGenerator{}).Exchange(context.Background(), client, ExchangeRequest{
+		SubjectToken:     "user",
+		SubjectTokenType: TokenTypeAccessToken,
+		Audience:         []string{"orders"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.ExpiresIn > 60 {
+		t.Errorf("expires_in = %d, want at most the 60s left on the subject token", response.ExpiresIn)
+	}
+}
+
+func TestExchangeBoundSubjectToken(t *testing.T) {
+	subjects := testutil.StubValidator{
+		"dpop": {
+			Scope:            "orders:read",
+			Cnf:              &model.Confirmation{Jkt: "holder"},
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"},
+		},
+		"mtls": {
+			Scope:            "orders:read",
+			Cnf:              &model.Confirmation{X5tS256: "holder-cert"},
+			RegisteredClaims: jwt.RegisteredClaims{Subject: "user-1"},
+		},
+	}
+	client := &Client{ID: "gateway", TokenExchange: ExchangePolicy{Audiences: []string{"orders"}, Scopes: []string{"orders:read"}}}
+	tests := []struct {
+		name          string
+		token         string
+		jkt           string
+		x5t           string
+		wantErr       bool
+		wantCnf       *model.Confirmation
+		wantTokenType string
+	}{
+		{name: "dpop without proof", token: "dpop", wantErr: true},
+		{name: "dpop with another key", token: "dpop", jkt: "attacker", wantErr: true},
+		{name: "dpop with the holder key", token: "dpop", jkt: "holder", wantCnf: &model.Confirmation{Jkt: "holder"}, wantTokenType: "DPoP"},
+		{name: "mtls without certificate", token: "mtls", wantErr: true},
+		{name: "mtls with another certificate", token: "mtls", x5t: "attacker-cert", wantErr: true},
+		{name: "mtls with the holder certificate", token: "mtls", x5t: "holder-cert", wantCnf: &model.Confirmation{X5tS256: "holder-cert"}, wantTokenType: "Bearer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.jkt != "" {
+				ctx = context.WithValue(ctx, jktKey{}, tt.jkt)
+			}
+			if tt.x5t != "" {
+				ctx = context.WithValue(ctx, x5tKey{}, tt.x5t)
+			}
+			generator := &recordingGenerator{}
+			response, err := NewExchanger(subjects, generator).Exchange(ctx, client, ExchangeRequest{
+				SubjectToken:     tt.token,
+				SubjectTokenType: TokenTypeAccessToken,
+				Audience:         []string{"orders"},
+			})
+			if tt.wantErr {
+				var oauthErr *Error
+				if !errors.As(err, &oauthErr) || oauthErr.Code != ErrorInvalidRequest {
+					t.Fatalf("error = %v, want invalid_request", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(generator.claims.Cnf, tt.wantCnf) {
+				t.Errorf("cnf = %+v, want %+v", generator.claims.Cnf, tt.wantCnf)
+			}
+			if response.TokenType != tt.wantTokenType {
+				t.Errorf("token_type = %q, want %q", response.TokenType, tt.wantTokenType)
+			}
+		})
+	}
+}
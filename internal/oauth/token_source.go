@@ -0,0 +1,148 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// refreshBefore is how long before expiry a cached token is replaced, so a
+// token is not sent just as it expires.
+const refreshBefore = 30 * time.Second
+
+// DefaultTokenRequestTimeout bounds a token request when the source uses its
+// default HTTP client.
+const DefaultTokenRequestTimeout = 5 * time.Second
+
+// ClientCredentialsSource fetches tokens with the client_credentials grant
+// and caches them until shortly before they expire. Its Token and Generate
+// methods let it back grpcauth.PerRPCCredentials.
+type ClientCredentialsSource struct {
+	mu           sync.Mutex
+	tokenUrl     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	httpClient   *http.Client
+	token        string
+	expiry       time.Time
+	pending      *tokenFetch
+}
+
+// tokenFetch is a token request shared by the callers waiting for it.
+type tokenFetch struct {
+	done  chan struct{}
+	token string
+	err   error
+}
+
+func NewClientCredentialsSource(tokenUrl string, clientID string, clientSecret string, scopes ...string) *ClientCredentialsSource {
+	return &ClientCredentialsSource{
+		tokenUrl:     tokenUrl,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		httpClient:   &http.Client{Timeout: DefaultTokenRequestTimeout},
+	}
+}
+
+func (s *ClientCredentialsSource) SetHTTPClient(client *http.Client) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.httpClient = client
+}
+
+// Token returns the cached token or fetches a new one. Concurrent callers
+// share a single request, which outlives a caller that gives up when ctx is
+// done so that the others still get its token.
+func (s *ClientCredentialsSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	if s.token != "" && time.Now().Before(s.expiry.Add(-refreshBefore)) {
+		token := s.token
+		s.mu.Unlock()
+		return token, nil
+	}
+	fetch := s.pending
+	if fetch == nil {
+		fetch = &tokenFetch{done: make(chan struct{})}
+		s.pending = fetch
+		go s.run(context.WithoutCancel(ctx), fetch, s.httpClient)
+	}
+	s.mu.Unlock()
+	select {
+	case <-fetch.done:
+		return fetch.token, fetch.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// run fetches a token for fetch and caches it. Without the caller's
+// cancellation the request is bounded by the client's timeout.
+func (s *ClientCredentialsSource) run(ctx context.Context, fetch *tokenFetch, client *http.Client) {
+	response, err := s.fetch(ctx, client)
+	s.mu.Lock()
+	if err == nil {
+		s.token = response.AccessToken
+		s.expiry = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+		fetch.token = response.AccessToken
+	}
+	fetch.err = err
+	s.pending = nil
+	s.mu.Unlock()
+	close(fetch.done)
+}
+
+// Generate is Token for callers without a context. The request is bounded
+// by the HTTP client's timeout only.
+func (s *ClientCredentialsSource) Generate() (string, error) {
+	return s.Token(context.Background())
+}
+
+// Invalidate drops the cached token, for example after a resource server
+// rejected it.
+func (s *ClientCredentialsSource) Invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = ""
+}
+
+func (s *ClientCredentialsSource) fetch(ctx context.Context, client *http.Client) (*TokenResponse, error) {
+	form := url.Values{"grant_type": {GrantTypeClientCredentials}}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		oauthErr := &Error{Status: res.StatusCode}
+		err = json.NewDecoder(res.Body).Decode(oauthErr)
+		if err != nil || oauthErr.Code == "" {
+			return nil, fmt.Errorf("token endpoint returned %s", res.Status)
+		}
+		return nil, oauthErr
+	}
+	var response TokenResponse
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return nil, err
+	}
+	if response.AccessToken == "" {
+		return nil, fmt.Errorf("token endpoint returned no access_token")
+	}
+	return &response, nil
+}
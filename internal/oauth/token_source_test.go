@@ -0,0 +1,90 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// newTokenServer returns a token endpoint that answers after release is
+// closed and counts its requests.
+func newTokenServer(t *testing.T, release chan struct{}) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(TokenResponse{AccessToken: "token", TokenType: "Bearer", ExpiresIn: 300})
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestClientCredentialsSourceSharesRequest(t *testing.T) {
+	release := make(chan struct{})
+	server, requests := newTokenServer(t, release)
+	source := NewClientCredentialsSource(server.URL, "orders", "orders-secret")
+	if source.httpClient.Timeout != DefaultTokenRequestTimeout {
+		t.Errorf("timeout = %v, want %v", source.httpClient.Timeout, DefaultTokenRequestTimeout)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 3)
+	for range 3 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := source.Token(context.Background())
+			if err == nil && token != "token" {
+				err = errors.New("unexpected token " + token)
+			}
+			errs <- err
+		}()
+	}
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
+
+func TestClientCredentialsSourceCancelledCaller(t *testing.T) {
+	release := make(chan struct{})
+	server, requests := newTokenServer(t, release)
+	source := NewClientCredentialsSource(server.URL, "orders", "orders-secret")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := source.Token(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	// The request started for the cancelled caller still serves the next.
+	close(release)
+	token, err := source.Token(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "token" {
+		t.Errorf("token = %q, want token", token)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("requests = %d, want 1", n)
+	}
+}
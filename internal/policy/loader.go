@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RuleSpec is the file form of a rule. Exactly one condition is set.
+type RuleSpec struct {
+	Claim       string     `json:"claim,omitempty" yaml:"claim,omitempty"`
+	Exists      bool       `json:"exists,omitempty" yaml:"exists,omitempty"`
+	Equals      any        `json:"equals,omitempty" yaml:"equals,omitempty"`
+	EqualsParam string     `json:"equals_param,omitempty" yaml:"equals_param,omitempty"`
+	Contains    any        `json:"contains,omitempty" yaml:"contains,omitempty"`
+	OneOf       []any      `json:"one_of,omitempty" yaml:"one_of,omitempty"`
+	All         []RuleSpec `json:"all,omitempty" yaml:"all,omitempty"`
+	Any         []RuleSpec `json:"any,omitempty" yaml:"any,omitempty"`
+}
+
+// File maps policy names, such as a route group, to their rules.
+type File struct {
+	Policies map[string][]RuleSpec `json:"policies" yaml:"policies"`
+}
+
+// LoadFile reads named policies from a .json, .yaml or .yml file.
+func LoadFile(path string) (map[string]*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file File
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &file)
+	default:
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	policies := make(map[string]*Policy, len(file.Policies))
+	for name, specs := range file.Policies {
+		rules, err := buildRules(specs)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", name, err)
+		}
+		policies[name] = New(rules...)
+	}
+	return policies, nil
+}
+
+func buildRules(specs []RuleSpec) ([]Rule, error) {
+	rules := make([]Rule, 0, len(specs))
+	for i, spec := range specs {
+		rule, err := spec.Build()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Build returns the rule of the spec. A spec that sets no condition or more
+// than one is rejected rather than having conditions silently ignored.
+func (s RuleSpec) Build() (Rule, error) {
+	conditions := s.conditions()
+	if len(conditions) != 1 {
+		return nil, fmt.Errorf("rule must set exactly one condition, got %d %v", len(conditions), conditions)
+	}
+	switch conditions[0] {
+	case "all", "any":
+		if s.Claim != "" {
+			return nil, fmt.Errorf("%s rule cannot set a claim", conditions[0])
+		}
+		specs := s.All
+		if conditions[0] == "any" {
+			specs = s.Any
+		}
+		rules, err := buildRules(specs)
+		if err != nil {
+			return nil, err
+		}
+		if conditions[0] == "any" {
+			return Any(rules...), nil
+		}
+		return All(rules...), nil
+	}
+	if s.Claim == "" {
+		return nil, fmt.Errorf("rule needs a claim")
+	}
+	switch conditions[0] {
+	case "equals":
+		return Equals(s.Claim, s.Equals), nil
+	case "equals_param":
+		return EqualsParam(s.Claim, s.EqualsParam), nil
+	case "contains":
+		return Contains(s.Claim, s.Contains), nil
+	case "one_of":
+		return OneOf(s.Claim, s.OneOf...), nil
+	}
+	return Exists(s.Claim), nil
+}
+
+// conditions returns the names of the conditions the spec sets.
+func (s RuleSpec) conditions() []string {
+	var set []string
+	for _, condition := range []struct {
+		name string
+		set  bool
+	}{
+		{"exists", s.Exists},
+		{"equals", s.Equals != nil},
+		{"equals_param", s.EqualsParam != ""},
+		{"contains", s.Contains != nil},
+		{"one_of", len(s.OneOf) > 0},
+		{"all", len(s.All) > 0},
+		{"any", len(s.Any) > 0},
+	} {
+		if condition.set {
+			set = append(set, condition.name)
+		}
+	}
+	return set
+}
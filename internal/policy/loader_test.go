@@ -0,0 +1,65 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+func writePolicyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	err := os.WriteFile(path, []byte(content), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadFileRejectsAmbiguousRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"no condition", "policies:\n  admin:\n    - claim: tenant\n"},
+		{"two conditions", "policies:\n  admin:\n    - claim: tenant\n      exists: true\n      equals: acme\n"},
+		{"composite with condition", "policies:\n  admin:\n    - any:\n        - claim: tenant\n          exists: true\n      one_of: [a]\n      claim: tenant\n"},
+		{"composite with claim", "policies:\n  admin:\n    - claim: tenant\n      all:\n        - claim: tenant\n          exists: true\n"},
+		{"nested two conditions", "policies:\n  admin:\n    - all:\n        - claim: tenant\n          contains: a\n          one_of: [a]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFile(writePolicyFile(t, tt.content))
+			if err == nil {
+				t.Fatal("loaded an ambiguous rule")
+			}
+		})
+	}
+}
+
+func TestViolationsDoNotEchoValues(t *testing.T) {
+	policies, err := LoadFile(writePolicyFile(t, `policies:
+  tenant:
+    - claim: client_id
+      equals_param: client
+    - claim: sub
+      one_of: [admin]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &model.JwtClaim{ClientID: "secret-client"}
+	claims.Subject = "secret-subject"
+	err = policies["tenant"].Evaluate(Input{Claims: claims, Params: map[string]string{"client": "other-client"}})
+	if err == nil {
+		t.Fatal("policy passed")
+	}
+	for _, value := range []string{"secret-client", "secret-subject", "other-client", "admin"} {
+		if strings.Contains(err.Error(), value) {
+			t.Errorf("error %q echoes %s", err, value)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+// Input is what rules are evaluated against: the verified claims and request
+// values such as path parameters.
+type Input struct {
+	Claims *model.JwtClaim
+	Params map[string]string
+}
+
+type Rule interface {
+	Evaluate(in Input) error
+}
+
+type RuleFunc func(in Input) error
+
+func (f RuleFunc) Evaluate(in Input) error {
+	return f(in)
+}
+
+// Violation is the reason a rule failed. Reasons name the claim but never
+// echo claim or request values, since they end up in WWW-Authenticate.
+type Violation struct {
+	Claim  string
+	Reason string
+}
+
+func (v *Violation) Error() string {
+	return fmt.Sprintf("claim %s: %s", v.Claim, v.Reason)
+}
+
+// Policy passes when every rule passes. Evaluate reports every failing rule.
+type Policy struct {
+	rules []Rule
+}
+
+func New(rules ...Rule) *Policy {
+	return &Policy{rules: rules}
+}
+
+func (p *Policy) Evaluate(in Input) error {
+	var errs []error
+	for _, rule := range p.rules {
+		err := rule.Evaluate(in)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func Exists(claim string) Rule {
+	return RuleFunc(func(in Input) error {
+		if _, ok := in.Claims.Claim(claim); !ok {
+			return &Violation{Claim: claim, Reason: "is missing"}
+		}
+		return nil
+	})
+}
+
+func Equals(claim string, expected any) Rule {
+	return RuleFunc(func(in Input) error {
+		value, ok := in.Claims.Claim(claim)
+		if !ok {
+			return &Violation{Claim: claim, Reason: "is missing"}
+		}
+		if !equal(value, expected) {
+			return &Violation{Claim: claim, Reason: "does not have the required value"}
+		}
+		return nil
+	})
+}
+
+// EqualsParam requires the claim to equal a request parameter, such as the
+// tenant in the request path.
+func EqualsParam(claim string, param string) Rule {
+	return RuleFunc(func(in Input) error {
+		expected, ok := in.Params[param]
+		if !ok {
+			return &Violation{Claim: claim, Reason: fmt.Sprintf("cannot be checked without the %s parameter", param)}
+		}
+		value, ok := in.Claims.Claim(claim)
+		if !ok {
+			return &Violation{Claim: claim, Reason: "is missing"}
+		}
+		if !equal(value, expected) {
+			return &Violation{Claim: claim, Reason: fmt.Sprintf("does not match the request %s", param)}
+		}
+		return nil
+	})
+}
+
+// Contains requires an array claim, or a space-delimited string claim, to
+// contain expected.
+func Contains(claim string, expected any) Rule {
+	return RuleFunc(func(in Input) error {
+		value, ok := in.Claims.Claim(claim)
+		if !ok {
+			return &Violation{Claim: claim, Reason: "is missing"}
+		}
+		var items []any
+		switch v := value.(type) {
+		case []any:
+			items = v
+		case string:
+			for _, field := range strings.Fields(v) {
+				items = append(items, field)
+			}
+		default:
+			return &Violation{Claim: claim, Reason: "is not a list"}
+		}
+		for _, item := range items {
+			if equal(item, expected) {
+				return nil
+			}
+		}
+		return &Violation{Claim: claim, Reason: "does not contain the required value"}
+	})
+}
+
+func OneOf(claim string, allowed ...any) Rule {
+	return RuleFunc(func(in Input) error {
+		value, ok := in.Claims.Claim(claim)
+		if !ok {
+			return &Violation{Claim: claim, Reason: "is missing"}
+		}
+		for _, candidate := range allowed {
+			if equal(value, candidate) {
+				return nil
+			}
+		}
+		return &Violation{Claim: claim, Reason: "is not an allowed value"}
+	})
+}
+
+// All passes when every rule passes.
+func All(rules ...Rule) Rule {
+	return RuleFunc(func(in Input) error {
+		return New(rules...).Evaluate(in)
+	})
+}
+
+// Any passes when at least one rule passes.
+func Any(rules ...Rule) Rule {
+	return RuleFunc(func(in Input) error {
+		var errs []error
+		for _, rule := range rules {
+			err := rule.Evaluate(in)
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		return fmt.Errorf("no alternative passed: %w", errors.Join(errs...))
+	})
+}
+
+// equal compares claim values, treating all numeric types alike since JSON
+// and YAML decode numbers differently, and typed slices such as []string
+// like the []any of decoded claims.
+func equal(a any, b any) bool {
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func normalize(value any) any {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Slice, reflect.Array:
+		out := make([]any, v.Len())
+		for i := range out {
+			out[i] = normalize(v.Index(i).Interface())
+		}
+		return out
+	}
+	return value
+}
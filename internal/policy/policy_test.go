@@ -0,0 +1,70 @@
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+func TestRules(t *testing.T) {
+	claims := &model.JwtClaim{}
+	err := json.Unmarshal([]byte(`{
+		"sub": "user-1",
+		"tenant": "acme",
+		"level": 3,
+		"amr": ["pwd", "mfa"],
+		"scope": "orders:read orders:write",
+		"realm_access": {"roles": ["admin"]}
+	}`), claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := map[string]string{"tenant": "acme", "other": "globex"}
+	tests := []struct {
+		name string
+		rule Rule
+		want bool
+	}{
+		{"exists", Exists("tenant"), true},
+		{"exists nested", Exists("realm_access.roles"), true},
+		{"missing", Exists("department"), false},
+		{"equals string", Equals("tenant", "acme"), true},
+		{"equals other string", Equals("tenant", "globex"), false},
+		{"equals int", Equals("level", 3), true},
+		{"equals int64", Equals("level", int64(3)), true},
+		{"equals int32", Equals("level", int32(3)), true},
+		{"equals uint8", Equals("level", uint8(3)), true},
+		{"equals float32", Equals("level", float32(3)), true},
+		{"equals other number", Equals("level", int64(4)), false},
+		{"equals number as string", Equals("level", "3"), false},
+		{"equals string slice", Equals("amr", []string{"pwd", "mfa"}), true},
+		{"equals string slice in other order", Equals("amr", []string{"mfa", "pwd"}), false},
+		{"equals any slice", Equals("amr", []any{"pwd", "mfa"}), true},
+		{"one of numbers", OneOf("level", int64(1), uint(3)), true},
+		{"one of strings", OneOf("tenant", "globex", "initech"), false},
+		{"contains in array", Contains("amr", "mfa"), true},
+		{"contains missing in array", Contains("amr", "otp"), false},
+		{"contains in string", Contains("scope", "orders:write"), true},
+		{"contains substring of string", Contains("scope", "orders"), false},
+		{"contains in nested array", Contains("realm_access.roles", "admin"), true},
+		{"contains in number", Contains("level", 3), false},
+		{"equals param", EqualsParam("tenant", "tenant"), true},
+		{"equals other param", EqualsParam("tenant", "other"), false},
+		{"equals missing param", EqualsParam("tenant", "department"), false},
+		{"all", All(Exists("tenant"), Contains("amr", "mfa")), true},
+		{"all with a failure", All(Exists("tenant"), Contains("amr", "otp")), false},
+		{"any", Any(Equals("tenant", "globex"), Contains("amr", "mfa")), true},
+		{"any without a pass", Any(Equals("tenant", "globex"), Contains("amr", "otp")), false},
+		{"any of all", Any(All(Equals("tenant", "globex"), Exists("sub")), All(EqualsParam("tenant", "tenant"), Equals("level", 3))), true},
+		{"all of any", All(Any(Equals("tenant", "globex"), Exists("sub")), Any(Equals("level", 4), Contains("amr", "otp"))), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Evaluate(Input{Claims: claims, Params: params})
+			if (err == nil) != tt.want {
+				t.Errorf("error = %v, want pass %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package policy
+
+import (
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+type ClaimsValidator interface {
+	ValidateClaims(token string) (*model.JwtClaim, error)
+}
+
+// Validator evaluates a policy after the wrapped validator has verified the
+// token signature.
+type Validator struct {
+	validator ClaimsValidator
+	policy    *Policy
+}
+
+func NewValidator(validator ClaimsValidator, policy *Policy) *Validator {
+	return &Validator{
+		validator: validator,
+		policy:    policy,
+	}
+}
+
+func (v *Validator) ValidateClaims(token string) (*model.JwtClaim, error) {
+	return v.ValidateClaimsWithParams(token, nil)
+}
+
+func (v *Validator) ValidateClaimsWithParams(token string, params map[string]string) (*model.JwtClaim, error) {
+	claims, err := v.validator.ValidateClaims(token)
+	if err != nil {
+		return nil, err
+	}
+	err = v.policy.Evaluate(Input{Claims: claims, Params: params})
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
@@ -0,0 +1,279 @@
+package refresh
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/revocation"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrInvalidGrant is returned for refresh tokens that are unknown, expired,
+// revoked or reused. Reuse is also reported as ErrTokenReused, so callers can
+// tell a replay from an expired token.
+var ErrInvalidGrant = errors.New("invalid refresh token")
+
+// ErrProofKeyMismatch is returned with ErrInvalidGrant when a refresh token
+// bound to a DPoP key is presented without a proof of that key.
+var ErrProofKeyMismatch = errors.New("refresh token is bound to a different dpop key")
+
+// ErrClientMismatch is returned when a client acts on a refresh token issued
+// to another client.
+var ErrClientMismatch = errors.New("refresh token was not issued to this client")
+
+// DefaultTTL is the lifetime of a refresh token. Each refresh issues a new
+// token with a fresh lifetime, up to the end of its family.
+const DefaultTTL = 30 * 24 * time.Hour
+
+// DefaultFamilyLifetime is how long a token family can be refreshed before
+// the subject has to authenticate again.
+const DefaultFamilyLifetime = 90 * 24 * time.Hour
+
+type ClaimsGenerator interface {
+	GenerateClaims(claims *model.JwtClaim) (string, error)
+}
+
+// TokenPair is an access token with the refresh token issued alongside it.
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresAt    time.Time
+	Scopes       []string
+}
+
+// Issuer issues opaque refresh tokens and rotates them on every refresh. A
+// replayed refresh token revokes its whole family: every refresh token
+// descended from the same Issue call, and their access tokens.
+type Issuer struct {
+	mu          sync.RWMutex
+	generator   ClaimsGenerator
+	store       Store
+	ttl         time.Duration
+	lifetime    time.Duration
+	revocations revocation.RevocationStore
+	now         func() time.Time
+}
+
+func NewIssuer(generator ClaimsGenerator, store Store, ttl time.Duration) *Issuer {
+	return &Issuer{
+		generator: generator,
+		store:     store,
+		ttl:       ttl,
+		lifetime:  DefaultFamilyLifetime,
+		now:       time.Now,
+	}
+}
+
+// SetFamilyLifetime sets how long after Issue a token family can still be
+// refreshed. It applies to families issued afterwards.
+func (i *Issuer) SetFamilyLifetime(lifetime time.Duration) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.lifetime = lifetime
+}
+
+// SetRevocationStore makes the issuer revoke the access tokens of a family
+// when reuse is detected.
+func (i *Issuer) SetRevocationStore(store revocation.RevocationStore) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.revocations = store
+}
+
+// Binding is what a token family is bound to when it is issued.
+type Binding struct {
+	// ClientID is the client the family is issued to. Its access tokens
+	// carry it as client_id.
+	ClientID string
+	// Jkt is the thumbprint of the DPoP key the family is bound to, as in
+	// RFC 9449 section 5. Its access tokens carry it as cnf.jkt.
+	Jkt string
+}
+
+// Issue starts a new token family for subject.
+func (i *Issuer) Issue(ctx context.Context, subject string, scopes ...string) (*TokenPair, error) {
+	return i.IssueBound(ctx, Binding{}, subject, scopes...)
+}
+
+// IssueBound starts a new token family for subject bound to binding.
+func (i *Issuer) IssueBound(ctx context.Context, binding Binding, subject string, scopes ...string) (*TokenPair, error) {
+	family, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	i.mu.RLock()
+	lifetime := i.lifetime
+	i.mu.RUnlock()
+	token := Token{
+		Family:          family,
+		Subject:         subject,
+		Scopes:          scopes,
+		ClientID:        binding.ClientID,
+		Jkt:             binding.Jkt,
+		FamilyExpiresAt: i.now().Add(lifetime),
+	}
+	pair, next, err := i.next(token, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = i.store.Create(ctx, next)
+	if err != nil {
+		return nil, err
+	}
+	return pair, nil
+}
+
+// Refresh exchanges refreshToken for a new token pair. The old refresh token
+// cannot be used again, and stays usable if the new pair cannot be issued.
+// Tokens of families bound to a DPoP key need RefreshClaims.
+func (i *Issuer) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	return i.RefreshClaims(ctx, refreshToken, "", nil)
+}
+
+// RefreshClaims is Refresh for a request proven with the DPoP key of
+// thumbprint jkt, empty without a proof. edit is applied to the claims of
+// the new access token before it is signed, for example to bind it to the
+// client certificate.
+func (i *Issuer) RefreshClaims(ctx context.Context, refreshToken string, jkt string, edit func(claims *model.JwtClaim)) (*TokenPair, error) {
+	hash := hashToken(refreshToken)
+	token, err := i.store.Get(ctx, hash)
+	if err == nil && token.Jkt != "" && token.Jkt != jkt {
+		// A stolen refresh token is of no use without the key.
+		return nil, errors.Join(ErrInvalidGrant, ErrProofKeyMismatch)
+	}
+	if err == nil {
+		var pair *TokenPair
+		var next Token
+		pair, next, err = i.next(token, edit)
+		if err != nil {
+			return nil, err
+		}
+		token, err = i.store.Rotate(ctx, hash, next)
+		if err == nil {
+			return pair, nil
+		}
+	}
+	if errors.Is(err, ErrTokenReused) {
+		revokeErr := i.revokeFamily(ctx, token.Family)
+		if revokeErr != nil {
+			return nil, revokeErr
+		}
+		return nil, errors.Join(ErrInvalidGrant, ErrTokenReused)
+	}
+	if errors.Is(err, ErrTokenNotFound) || errors.Is(err, ErrTokenRevoked) {
+		return nil, ErrInvalidGrant
+	}
+	return nil, err
+}
+
+// RevokeFamily revokes the family of refreshToken on behalf of clientID, as
+// on logout. It fails with ErrClientMismatch for families issued to another
+// client.
+func (i *Issuer) RevokeFamily(ctx context.Context, refreshToken string, clientID string) error {
+	token, err := i.store.Get(ctx, hashToken(refreshToken))
+	if errors.Is(err, ErrTokenNotFound) {
+		return nil
+	}
+	if err != nil && !errors.Is(err, ErrTokenReused) && !errors.Is(err, ErrTokenRevoked) {
+		return err
+	}
+	if token.ClientID != clientID {
+		return ErrClientMismatch
+	}
+	return i.revokeFamily(ctx, token.Family)
+}
+
+// next issues the token pair that follows token in its family and returns it
+// with the refresh token to store. Refresh tokens never outlive the family.
+func (i *Issuer) next(token Token, edit func(claims *model.JwtClaim)) (*TokenPair, Token, error) {
+	now := i.now()
+	if !now.Before(token.FamilyExpiresAt) {
+		return nil, Token{}, ErrTokenNotFound
+	}
+	claims := &model.JwtClaim{
+		Scope:    strings.Join(token.Scopes, " "),
+		ClientID: token.ClientID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject: token.Subject,
+		},
+	}
+	if token.Jkt != "" {
+		claims.Cnf = &model.Confirmation{Jkt: token.Jkt}
+	}
+	if edit != nil {
+		edit(claims)
+	}
+	accessToken, err := i.generator.GenerateClaims(claims)
+	if err != nil {
+		return nil, Token{}, err
+	}
+	refreshToken, err := randomToken()
+	if err != nil {
+		return nil, Token{}, err
+	}
+	next := Token{
+		Hash:            hashToken(refreshToken),
+		Family:          token.Family,
+		Subject:         token.Subject,
+		Scopes:          token.Scopes,
+		ClientID:        token.ClientID,
+		Jkt:             token.Jkt,
+		AccessJti:       claims.ID,
+		AccessExpiresAt: claims.ExpiresAt.Time,
+		ExpiresAt:       now.Add(i.ttl),
+		FamilyExpiresAt: token.FamilyExpiresAt,
+	}
+	if next.ExpiresAt.After(next.FamilyExpiresAt) {
+		next.ExpiresAt = next.FamilyExpiresAt
+	}
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresAt:    next.AccessExpiresAt,
+		Scopes:       next.Scopes,
+	}, next, nil
+}
+
+func (i *Issuer) revokeFamily(ctx context.Context, family string) error {
+	tokens, err := i.store.RevokeFamily(ctx, family)
+	if err != nil {
+		return err
+	}
+	i.mu.RLock()
+	revocations := i.revocations
+	i.mu.RUnlock()
+	if revocations == nil {
+		return nil
+	}
+	for _, token := range tokens {
+		if token.AccessJti == "" {
+			continue
+		}
+		err = revocations.RevokeToken(ctx, token.AccessJti, token.AccessExpiresAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,125 @@
+package refresh
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// stubGenerator fills in the claims a generator would, failing while fail
+// is set.
+type stubGenerator struct {
+	issued int
+	fail   bool
+}
+
+func (g *stubGenerator) GenerateClaims(claims *model.JwtClaim) (string, error) {
+	if g.fail {
+		return "", errors.New("no signing key")
+	}
+	g.issued++
+	claims.ID = "jti"
+	claims.ExpiresAt = jwt.NewNumericDate(time.Now().Add(time.Minute))
+	return "access", nil
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	ctx := context.Background()
+	issuer := NewIssuer(&stubGenerator{}, NewMemoryStore(), time.Hour)
+	pair, err := issuer.Issue(ctx, "user-1", "read")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := issuer.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = issuer.Refresh(ctx, pair.RefreshToken)
+	if !errors.Is(err, ErrInvalidGrant) || !errors.Is(err, ErrTokenReused) {
+		t.Fatalf("reuse error = %v, want ErrInvalidGrant and ErrTokenReused", err)
+	}
+	_, err = issuer.Refresh(ctx, next.RefreshToken)
+	if !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("refresh after reuse error = %v, want the family revoked", err)
+	}
+}
+
+func TestRefreshKeepsTokenWhenIssueFails(t *testing.T) {
+	ctx := context.Background()
+	generator := &stubGenerator{}
+	issuer := NewIssuer(generator, NewMemoryStore(), time.Hour)
+	pair, err := issuer.Issue(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	generator.fail = true
+	_, err = issuer.Refresh(ctx, pair.RefreshToken)
+	if err == nil || errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("error = %v, want the generator error", err)
+	}
+	generator.fail = false
+	_, err = issuer.Refresh(ctx, pair.RefreshToken)
+	if err != nil {
+		t.Fatalf("retry after a failed issue: %v", err)
+	}
+}
+
+func TestRefreshEndsWithFamily(t *testing.T) {
+	ctx := context.Background()
+	start := time.Now()
+	now := start
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	issuer := NewIssuer(&stubGenerator{}, store, time.Hour)
+	issuer.now = store.now
+	issuer.SetFamilyLifetime(90 * time.Minute)
+	pair, err := issuer.Issue(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Refreshing within the token TTL keeps the family alive only until its
+	// lifetime ends.
+	for _, elapsed := range []time.Duration{45 * time.Minute, 89 * time.Minute} {
+		now = start.Add(elapsed)
+		pair, err = issuer.Refresh(ctx, pair.RefreshToken)
+		if err != nil {
+			t.Fatalf("refresh after %v: %v", elapsed, err)
+		}
+		token, err := store.Get(ctx, hashToken(pair.RefreshToken))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token.ExpiresAt.After(token.FamilyExpiresAt) {
+			t.Errorf("refresh token expires at %v, after its family at %v", token.ExpiresAt, token.FamilyExpiresAt)
+		}
+	}
+	now = start.Add(91 * time.Minute)
+	_, err = issuer.Refresh(ctx, pair.RefreshToken)
+	if !errors.Is(err, ErrInvalidGrant) {
+		t.Fatalf("refresh after the family ended: error = %v, want ErrInvalidGrant", err)
+	}
+}
+
+func TestRefreshRequiresFamilyProofKey(t *testing.T) {
+	ctx := context.Background()
+	issuer := NewIssuer(&stubGenerator{}, NewMemoryStore(), time.Hour)
+	pair, err := issuer.IssueBound(ctx, Binding{Jkt: "owner"}, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, jkt := range []string{"", "attacker"} {
+		_, err = issuer.RefreshClaims(ctx, pair.RefreshToken, jkt, nil)
+		if !errors.Is(err, ErrInvalidGrant) || !errors.Is(err, ErrProofKeyMismatch) {
+			t.Errorf("jkt %q: error = %v, want ErrProofKeyMismatch", jkt, err)
+		}
+	}
+	_, err = issuer.RefreshClaims(ctx, pair.RefreshToken, "owner", nil)
+	if err != nil {
+		t.Fatalf("refresh with the family key: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package refresh
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTokenNotFound = errors.New("refresh token not found")
+	ErrTokenReused   = errors.New("refresh token was already used")
+	ErrTokenRevoked  = errors.New("refresh token is revoked")
+)
+
+// Token is the stored state of a refresh token. Only the hash of the token
+// is kept, so a leaked store cannot be used to refresh.
+type Token struct {
+	Hash    string
+	Family  string
+	Subject string
+	Scopes  []string
+	// ClientID is the client the family was issued to. Only that client may
+	// revoke it.
+	ClientID string
+	// Jkt is the thumbprint of the DPoP key the family is bound to, if any.
+	// Every refresh must be proven with that key.
+	Jkt string
+	// AccessJti and AccessExpiresAt identify the access token issued with
+	// this refresh token, so it can be revoked with the family.
+	AccessJti       string
+	AccessExpiresAt time.Time
+	ExpiresAt       time.Time
+	// FamilyExpiresAt is when the family ends, however often it is refreshed.
+	FamilyExpiresAt time.Time
+	Used            bool
+	Revoked         bool
+}
+
+// Store keeps refresh tokens. Rotate must be atomic so that two concurrent
+// refreshes with the same token cannot both succeed.
+type Store interface {
+	Create(ctx context.Context, token Token) error
+	// Get returns the token. A token that was used before is returned
+	// together with ErrTokenReused, a revoked one with ErrTokenRevoked.
+	Get(ctx context.Context, hash string) (Token, error)
+	// Rotate marks the token as used and creates next in one step. It fails
+	// like Get when the token cannot be used, and then next is not created.
+	Rotate(ctx context.Context, hash string, next Token) (Token, error)
+	// RevokeFamily revokes every token of family and returns them.
+	RevokeFamily(ctx context.Context, family string) ([]Token, error)
+}
+
+// MemoryStore keeps refresh tokens in memory and drops them once expired.
+type MemoryStore struct {
+	mu     sync.Mutex
+	tokens map[string]Token
+	now    func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens: make(map[string]Token),
+		now:    time.Now,
+	}
+}
+
+func (m *MemoryStore) Create(ctx context.Context, token Token) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purge()
+	m.tokens[token.Hash] = token
+	return nil
+}
+
+func (m *MemoryStore) Get(ctx context.Context, hash string) (Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.get(hash)
+}
+
+func (m *MemoryStore) Rotate(ctx context.Context, hash string, next Token) (Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token, err := m.get(hash)
+	if err != nil {
+		return token, err
+	}
+	token.Used = true
+	m.tokens[hash] = token
+	m.tokens[next.Hash] = next
+	return token, nil
+}
+
+func (m *MemoryStore) get(hash string) (Token, error) {
+	token, ok := m.tokens[hash]
+	if !ok || !m.now().Before(token.ExpiresAt) {
+		return Token{}, ErrTokenNotFound
+	}
+	if token.Revoked {
+		return token, ErrTokenRevoked
+	}
+	if token.Used {
+		return token, ErrTokenReused
+	}
+	return token, nil
+}
+
+func (m *MemoryStore) RevokeFamily(ctx context.Context, family string) ([]Token, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var revoked []Token
+	for hash, token := range m.tokens {
+		if token.Family != family {
+			continue
+		}
+		token.Revoked = true
+		m.tokens[hash] = token
+		revoked = append(revoked, token)
+	}
+	return revoked, nil
+}
+
+func (m *MemoryStore) purge() {
+	now := m.now()
+	for hash, token := range m.tokens {
+		if !now.Before(token.ExpiresAt) {
+			delete(m.tokens, hash)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrReplayed = errors.New("identifier was already used")
+
+// Cache records single-use identifiers, such as the jti of an assertion,
+// until the message carrying them expires.
+type Cache interface {
+	// Use records id until expiresAt and returns ErrReplayed if it was
+	// already recorded.
+	Use(ctx context.Context, id string, expiresAt time.Time) error
+}
+
+type MemoryCache struct {
+	mu  sync.Mutex
+	ids map[string]time.Time
+	now func() time.Time
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{
+		ids: make(map[string]time.Time),
+		now: time.Now,
+	}
+}
+
+func (m *MemoryCache) Use(ctx context.Context, id string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	if until, ok := m.ids[id]; ok && now.Before(until) {
+		return ErrReplayed
+	}
+	for seen, until := range m.ids {
+		if !now.Before(until) {
+			delete(m.ids, seen)
+		}
+	}
+	m.ids[id] = expiresAt
+	return nil
+}
@@ -0,0 +1,95 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+// FileStore is a MemoryStore that persists every change to a JSON file, so
+// revocations survive restarts.
+type FileStore struct {
+	*MemoryStore
+	path string
+	// saveMu serialises saves so an older snapshot is never renamed over a
+	// newer one.
+	saveMu sync.Mutex
+}
+
+func NewFileStore(path string, maxTokenTTL time.Duration) (*FileStore, error) {
+	store := &FileStore{
+		MemoryStore: NewMemoryStore(maxTokenTTL),
+		path:        path,
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return store, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &store.state)
+	if err != nil {
+		return nil, err
+	}
+	if store.state.Tokens == nil {
+		store.state.Tokens = make(map[string]time.Time)
+	}
+	if store.state.Subjects == nil {
+		store.state.Subjects = make(map[string]subjectCutoff)
+	}
+	store.Purge()
+	return store, nil
+}
+
+func (f *FileStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	err := f.MemoryStore.RevokeToken(ctx, jti, expiresAt)
+	if err != nil {
+		return err
+	}
+	return f.save()
+}
+
+func (f *FileStore) RevokeSubject(ctx context.Context, subject string, issuedBefore time.Time) error {
+	err := f.MemoryStore.RevokeSubject(ctx, subject, issuedBefore)
+	if err != nil {
+		return err
+	}
+	return f.save()
+}
+
+func (f *FileStore) IsRevoked(ctx context.Context, claims *model.JwtClaim) (bool, error) {
+	return f.MemoryStore.IsRevoked(ctx, claims)
+}
+
+// save writes the state to a temporary file and renames it over the old one
+// so a crash never leaves a partial file.
+func (f *FileStore) save() error {
+	f.saveMu.Lock()
+	defer f.saveMu.Unlock()
+	f.mu.RLock()
+	data, err := json.Marshal(f.state)
+	f.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), filepath.Base(f.path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
@@ -0,0 +1,61 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+func TestFileStoreKeepsConcurrentRevocations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "revocations.json")
+	store, err := NewFileStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.RevokeToken(context.Background(), fmt.Sprint("jti-", i), time.Now().Add(time.Hour))
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	reloaded, err := NewFileStore(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		claims := &model.JwtClaim{}
+		claims.ID = fmt.Sprint("jti-", i)
+		revoked, err := reloaded.IsRevoked(context.Background(), claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !revoked {
+			t.Errorf("%s was lost on reload", claims.ID)
+		}
+	}
+}
+
+func TestRevokeSubjectRejectsEmptySubject(t *testing.T) {
+	store := NewMemoryStore(time.Hour)
+	err := store.RevokeSubject(context.Background(), "", time.Now())
+	if !errors.Is(err, ErrNoSubject) {
+		t.Fatalf("error = %v, want ErrNoSubject", err)
+	}
+	revoked, err := store.IsRevoked(context.Background(), &model.JwtClaim{})
+	if err != nil || revoked {
+		t.Errorf("token without sub revoked = %v, %v", revoked, err)
+	}
+}
@@ -0,0 +1,133 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+// ErrNoSubject is returned when revoking tokens of an empty subject, which
+// would revoke every token without a sub claim.
+var ErrNoSubject = errors.New("revocation: no subject")
+
+// RevocationStore records revoked tokens until they would have expired anyway.
+type RevocationStore interface {
+	// RevokeToken revokes the token with jti until expiresAt, its exp claim.
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error
+	// RevokeSubject revokes every token of subject issued before issuedBefore.
+	RevokeSubject(ctx context.Context, subject string, issuedBefore time.Time) error
+	IsRevoked(ctx context.Context, claims *model.JwtClaim) (bool, error)
+}
+
+// subjectCutoff revokes tokens of a subject issued before Before. It can be
+// dropped at Expires, when every such token has expired.
+type subjectCutoff struct {
+	Before  time.Time `json:"before"`
+	Expires time.Time `json:"expires"`
+}
+
+type state struct {
+	Tokens   map[string]time.Time     `json:"tokens"`
+	Subjects map[string]subjectCutoff `json:"subjects"`
+}
+
+// MemoryStore keeps revocations in memory. Entries are purged once the tokens
+// they cover have expired.
+type MemoryStore struct {
+	mu          sync.RWMutex
+	state       state
+	maxTokenTTL time.Duration
+	now         func() time.Time
+}
+
+// NewMemoryStore returns a store for tokens that live at most maxTokenTTL,
+// which bounds how long subject revocations are kept.
+func NewMemoryStore(maxTokenTTL time.Duration) *MemoryStore {
+	return &MemoryStore{
+		state: state{
+			Tokens:   make(map[string]time.Time),
+			Subjects: make(map[string]subjectCutoff),
+		},
+		maxTokenTTL: maxTokenTTL,
+		now:         time.Now,
+	}
+}
+
+func (m *MemoryStore) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purge()
+	if expiresAt.After(m.now()) {
+		m.state.Tokens[jti] = expiresAt
+	}
+	return nil
+}
+
+func (m *MemoryStore) RevokeSubject(ctx context.Context, subject string, issuedBefore time.Time) error {
+	if subject == "" {
+		return ErrNoSubject
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purge()
+	cutoff := subjectCutoff{Before: issuedBefore, Expires: issuedBefore.Add(m.maxTokenTTL)}
+	if existing, ok := m.state.Subjects[subject]; ok && existing.Before.After(issuedBefore) {
+		cutoff = existing
+	}
+	if cutoff.Expires.After(m.now()) {
+		m.state.Subjects[subject] = cutoff
+	}
+	return nil
+}
+
+func (m *MemoryStore) IsRevoked(ctx context.Context, claims *model.JwtClaim) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if claims.ID != "" {
+		if _, ok := m.state.Tokens[claims.ID]; ok {
+			return true, nil
+		}
+	}
+	cutoff, ok := m.state.Subjects[claims.Subject]
+	if !ok {
+		return false, nil
+	}
+	// A token without iat cannot prove it was issued after the cutoff.
+	if claims.IssuedAt == nil {
+		return true, nil
+	}
+	return claims.IssuedAt.Time.Before(cutoff.Before), nil
+}
+
+// Purge drops entries for tokens that have expired.
+func (m *MemoryStore) Purge() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purge()
+}
+
+func (m *MemoryStore) purge() {
+	now := m.now()
+	for jti, expiresAt := range m.state.Tokens {
+		if !expiresAt.After(now) {
+			delete(m.state.Tokens, jti)
+		}
+	}
+	for subject, cutoff := range m.state.Subjects {
+		if !cutoff.Expires.After(now) {
+			delete(m.state.Subjects, subject)
+		}
+	}
+}
+
+// RevokeClaims revokes the token the claims were parsed from. Tokens without
+// a jti can only be revoked through their subject.
+func RevokeClaims(ctx context.Context, store RevocationStore, claims *model.JwtClaim) error {
+	if claims.ID != "" && claims.ExpiresAt != nil {
+		return store.RevokeToken(ctx, claims.ID, claims.ExpiresAt.Time)
+	}
+	return store.RevokeSubject(ctx, claims.Subject, time.Now())
+}
@@ -0,0 +1,174 @@
+package rotation
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/generator"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
+)
+
+type Config struct {
+	PublicBucket  string
+	JwksKey       string
+	PrivateBucket string
+	KeyBits       int
+	// PropagationDelay is how long a new key is published before generators
+	// start signing with it.
+	PropagationDelay time.Duration
+	// TokenTTL is the longest lifetime of a token signed by a retired key.
+	TokenTTL time.Duration
+}
+
+func DefaultConfig() Config {
+	return Config{
+		PublicBucket:     "goback-end-shared-bucket",
+		JwksKey:          ".well-known/jwks.json",
+		PrivateBucket:    "go-api-bucket-v1-21-6-2025",
+		KeyBits:          2048,
+		PropagationDelay: generator.DefaultPropagationDelay,
+		TokenTTL:         generator.TokenTTL,
+	}
+}
+
+// Rotator generates signing keys and maintains the published JWKS.
+type Rotator struct {
+	store     storage.ObjectStore
+	config    Config
+	converter converter.Converter[model.PrivateKeyJWK, *rsa.PrivateKey]
+	now       func() time.Time
+}
+
+func NewRotator(store storage.ObjectStore, config Config) *Rotator {
+	factory := converter.ConvertFactory{}
+	return &Rotator{
+		store:     store,
+		config:    config,
+		converter: factory.PrivateKeyToJwkConverter(),
+		now:       time.Now,
+	}
+}
+
+// Rotate generates a new key pair, stores its private JWK and prepends the
+// public JWK to the JWKS. The previous keys stay published so tokens they
+// signed keep validating; Prune removes them once those tokens have expired.
+func (r *Rotator) Rotate(ctx context.Context) (string, error) {
+	privateKey, err := converter.GenerateRsaKey(r.config.KeyBits)
+	if err != nil {
+		return "", err
+	}
+	privateJwk, err := r.converter.Convert(privateKey)
+	if err != nil {
+		return "", err
+	}
+	kid := privateJwk.Kid
+	now := r.now()
+	body, err := json.Marshal(privateJwk)
+	if err != nil {
+		return "", err
+	}
+	// The private key must exist before the JWKS points generators at it.
+	err = r.store.Put(ctx, r.config.PrivateBucket, privateObjectKey(kid), body)
+	if err != nil {
+		return "", err
+	}
+	jwks, err := r.loadJwks(ctx)
+	if err != nil {
+		return "", err
+	}
+	publicJwk := privateJwk.Public()
+	publicJwk.Nbf = now.Unix()
+	jwks.Keys = append([]model.PublicKeyJWK{publicJwk}, jwks.Keys...)
+	removed := r.prune(jwks, now)
+	err = r.saveJwks(ctx, jwks)
+	if err != nil {
+		return "", err
+	}
+	return kid, r.deletePrivateKeys(ctx, removed)
+}
+
+// Prune marks keys superseded by the current signing key as retiring and
+// removes them once the last token they could have signed has expired.
+func (r *Rotator) Prune(ctx context.Context) ([]string, error) {
+	jwks, err := r.loadJwks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	removed := r.prune(jwks, r.now())
+	err = r.saveJwks(ctx, jwks)
+	if err != nil {
+		return nil, err
+	}
+	return removed, r.deletePrivateKeys(ctx, removed)
+}
+
+func (r *Rotator) prune(jwks *model.JWKS, now time.Time) []string {
+	signingKey, ok := jwks.SigningKey(now, r.config.PropagationDelay)
+	if !ok {
+		return nil
+	}
+	// Generators stop signing with older keys once the signing key is active.
+	supersededAt := time.Unix(signingKey.Nbf, 0).Add(r.config.PropagationDelay)
+	expiredAt := supersededAt.Add(r.config.TokenTTL)
+	var removed []string
+	keys := make([]model.PublicKeyJWK, 0, len(jwks.Keys))
+	for _, key := range jwks.Keys {
+		if key.Kid == signingKey.Kid || key.Nbf >= signingKey.Nbf {
+			keys = append(keys, key)
+			continue
+		}
+		if !now.Before(expiredAt) {
+			removed = append(removed, key.Kid)
+			continue
+		}
+		key.Status = model.KeyStatusRetiring
+		keys = append(keys, key)
+	}
+	jwks.Keys = keys
+	return removed
+}
+
+func (r *Rotator) loadJwks(ctx context.Context) (*model.JWKS, error) {
+	body, err := r.store.Get(ctx, r.config.PublicBucket, r.config.JwksKey)
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return &model.JWKS{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var jwks model.JWKS
+	err = json.Unmarshal(body, &jwks)
+	if err != nil {
+		return nil, err
+	}
+	return &jwks, nil
+}
+
+func (r *Rotator) saveJwks(ctx context.Context, jwks *model.JWKS) error {
+	body, err := json.Marshal(jwks)
+	if err != nil {
+		return err
+	}
+	return r.store.Put(ctx, r.config.PublicBucket, r.config.JwksKey, body)
+}
+
+func (r *Rotator) deletePrivateKeys(ctx context.Context, kids []string) error {
+	var errs []error
+	for _, kid := range kids {
+		err := r.store.Delete(ctx, r.config.PrivateBucket, privateObjectKey(kid))
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func privateObjectKey(kid string) string {
+	return fmt.Sprintf("jwk-private-%s.json", kid)
+}
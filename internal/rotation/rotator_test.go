@@ -0,0 +1,124 @@
+package rotation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
+)
+
+func newTestRotator(t *testing.T) (*Rotator, *storage.MemoryStore, *time.Time) {
+	t.Helper()
+	store := storage.NewMemoryStore()
+	rotator := NewRotator(store, DefaultConfig())
+	now := time.Unix(1_700_000_000, 0)
+	rotator.now = func() time.Time { return now }
+	return rotator, store, &now
+}
+
+func loadJwks(t *testing.T, store *storage.MemoryStore) model.JWKS {
+	t.Helper()
+	config := DefaultConfig()
+	body, err := store.Get(context.Background(), config.PublicBucket, config.JwksKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jwks model.JWKS
+	err = json.Unmarshal(body, &jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwks
+}
+
+func hasPrivateKey(t *testing.T, store *storage.MemoryStore, kid string) bool {
+	t.Helper()
+	_, err := store.Get(context.Background(), DefaultConfig().PrivateBucket, privateObjectKey(kid))
+	if errors.Is(err, storage.ErrObjectNotFound) {
+		return false
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return true
+}
+
+func TestRotatePublishesPendingKey(t *testing.T) {
+	rotator, store, now := newTestRotator(t)
+	kid, err := rotator.Rotate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := loadJwks(t, store)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != kid {
+		t.Fatalf("keys = %v, want only %s", jwks.Kids(), kid)
+	}
+	if jwks.Keys[0].Nbf != now.Unix() {
+		t.Errorf("nbf = %d, want %d", jwks.Keys[0].Nbf, now.Unix())
+	}
+	if !hasPrivateKey(t, store, kid) {
+		t.Error("private key was not stored")
+	}
+	delay := DefaultConfig().PropagationDelay
+	if _, ok := jwks.SigningKey(*now, delay); ok {
+		t.Error("new key is active before it has propagated")
+	}
+	if key, ok := jwks.SigningKey(now.Add(delay), delay); !ok || key.Kid != kid {
+		t.Error("new key is not active after the propagation delay")
+	}
+}
+
+func TestRotateKeepsPreviousKeyUntilItsTokensExpire(t *testing.T) {
+	rotator, store, now := newTestRotator(t)
+	config := DefaultConfig()
+	first, err := rotator.Rotate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	*now = now.Add(24 * time.Hour)
+	second, err := rotator.Rotate(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := loadJwks(t, store)
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kid != second || jwks.Keys[1].Kid != first {
+		t.Fatalf("keys = %v, want [%s %s]", jwks.Kids(), second, first)
+	}
+	if jwks.Keys[1].Status != "" {
+		t.Errorf("status of the signing key = %q before the new key is active", jwks.Keys[1].Status)
+	}
+
+	// Once the new key signs, the old one only validates outstanding tokens.
+	*now = now.Add(config.PropagationDelay)
+	removed, err := rotator.Prune(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks = loadJwks(t, store)
+	if len(removed) != 0 || len(jwks.Keys) != 2 || jwks.Keys[1].Status != model.KeyStatusRetiring {
+		t.Fatalf("removed = %v, keys = %+v, want the first key retiring", removed, jwks.Keys)
+	}
+
+	*now = now.Add(config.TokenTTL)
+	removed, err = rotator.Prune(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0] != first {
+		t.Fatalf("removed = %v, want [%s]", removed, first)
+	}
+	jwks = loadJwks(t, store)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].Kid != second {
+		t.Fatalf("keys = %v, want [%s]", jwks.Kids(), second)
+	}
+	if hasPrivateKey(t, store, first) {
+		t.Error("private key of the removed key was not deleted")
+	}
+	if !hasPrivateKey(t, store, second) {
+		t.Error("private key of the signing key was deleted")
+	}
+}
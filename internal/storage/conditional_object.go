@@ -0,0 +1,96 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ErrNotModified is returned when the object has not changed since the last fetch.
+var ErrNotModified = errors.New("object not modified")
+
+// GetObjectClient is the part of *s3.Client that ConditionalObject uses.
+type GetObjectClient interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// ConditionalObject remembers the ETag and LastModified of an S3 object and
+// only downloads it again when it has changed.
+type ConditionalObject struct {
+	mu           sync.Mutex
+	client       GetObjectClient
+	bucket       string
+	key          string
+	etag         *string
+	lastModified *time.Time
+	body         []byte
+}
+
+func NewConditionalObject(client GetObjectClient, bucket string, key string) *ConditionalObject {
+	return &ConditionalObject{
+		client: client,
+		bucket: bucket,
+		key:    key,
+	}
+}
+
+// Get returns the object body, or ErrNotModified if it is unchanged since the
+// previous successful call.
+func (o *ConditionalObject) Get(ctx context.Context) ([]byte, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(o.bucket),
+		Key:    aws.String(o.key),
+	}
+	if o.etag != nil {
+		input.IfNoneMatch = o.etag
+	} else if o.lastModified != nil {
+		input.IfModifiedSince = o.lastModified
+	}
+	res, err := o.client.GetObject(ctx, input)
+	if err != nil {
+		if isNotModified(err) {
+			return nil, ErrNotModified
+		}
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	changed := o.body == nil || !bytes.Equal(o.body, body)
+	o.etag = res.ETag
+	o.lastModified = res.LastModified
+	o.body = body
+	if !changed {
+		return nil, ErrNotModified
+	}
+	return body, nil
+}
+
+// Invalidate forgets the remembered version so the next Get downloads the
+// object again.
+func (o *ConditionalObject) Invalidate() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.etag = nil
+	o.lastModified = nil
+	o.body = nil
+}
+
+func isNotModified(err error) bool {
+	var respErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &respErr) {
+		return respErr.HTTPStatusCode() == http.StatusNotModified
+	}
+	return false
+}
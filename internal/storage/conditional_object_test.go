@@ -0,0 +1,104 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// statusError is an error response with an HTTP status, like those of the
+// AWS SDK.
+type statusError int
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("status %d", int(e))
+}
+
+func (e statusError) HTTPStatusCode() int {
+	return int(e)
+}
+
+// fakeObject serves one object with an ETag and honours If-None-Match.
+type fakeObject struct {
+	body     string
+	etag     string
+	requests []*s3.GetObjectInput
+}
+
+func (f *fakeObject) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.requests = append(f.requests, params)
+	if params.IfNoneMatch != nil && *params.IfNoneMatch == f.etag {
+		return nil, statusError(http.StatusNotModified)
+	}
+	return &s3.GetObjectOutput{
+		Body: io.NopCloser(bytes.NewReader([]byte(f.body))),
+		ETag: aws.String(f.etag),
+	}, nil
+}
+
+func TestConditionalObjectGet(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeObject{body: "v1", etag: `"1"`}
+	object := NewConditionalObject(fake, "bucket", "key")
+
+	body, err := object.Get(ctx)
+	if err != nil || string(body) != "v1" {
+		t.Fatalf("first get = %q, %v, want v1", body, err)
+	}
+	if fake.requests[0].IfNoneMatch != nil {
+		t.Errorf("first get sent If-None-Match %q", *fake.requests[0].IfNoneMatch)
+	}
+
+	_, err = object.Get(ctx)
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("get after 304: error = %v, want ErrNotModified", err)
+	}
+	if got := aws.ToString(fake.requests[1].IfNoneMatch); got != `"1"` {
+		t.Errorf("If-None-Match = %q, want the remembered ETag", got)
+	}
+
+	// A new ETag with the same body is not a change either.
+	fake.etag = `"2"`
+	_, err = object.Get(ctx)
+	if !errors.Is(err, ErrNotModified) {
+		t.Fatalf("get of an unchanged body: error = %v, want ErrNotModified", err)
+	}
+
+	fake.body, fake.etag = "v2", `"3"`
+	body, err = object.Get(ctx)
+	if err != nil || string(body) != "v2" {
+		t.Fatalf("get after a change = %q, %v, want v2", body, err)
+	}
+
+	object.Invalidate()
+	body, err = object.Get(ctx)
+	if err != nil || string(body) != "v2" {
+		t.Fatalf("get after Invalidate = %q, %v, want v2", body, err)
+	}
+	if last := fake.requests[len(fake.requests)-1]; last.IfNoneMatch != nil {
+		t.Errorf("get after Invalidate sent If-None-Match %q", *last.IfNoneMatch)
+	}
+}
+
+func TestConditionalObjectGetError(t *testing.T) {
+	object := NewConditionalObject(errorClient{statusError(http.StatusForbidden)}, "bucket", "key")
+	_, err := object.Get(context.Background())
+	if err == nil || errors.Is(err, ErrNotModified) {
+		t.Fatalf("error = %v, want the 403 error", err)
+	}
+}
+
+type errorClient struct {
+	err error
+}
+
+func (c errorClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	return nil, c.err
+}
@@ -0,0 +1,99 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ErrObjectNotFound is returned when the requested object does not exist.
+var ErrObjectNotFound = errors.New("object not found")
+
+// ObjectStore is the minimal object storage used to publish keys.
+type ObjectStore interface {
+	Get(ctx context.Context, bucket string, key string) ([]byte, error)
+	Put(ctx context.Context, bucket string, key string, body []byte) error
+	Delete(ctx context.Context, bucket string, key string) error
+}
+
+type S3Store struct {
+	client *s3.Client
+}
+
+func NewS3Store(client *s3.Client) *S3Store {
+	return &S3Store{client: client}
+}
+
+func (s *S3Store) Get(ctx context.Context, bucket string, key string) ([]byte, error) {
+	res, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var respErr interface{ HTTPStatusCode() int }
+		if errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotFound {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	defer res.Body.Close()
+	return io.ReadAll(res.Body)
+}
+
+func (s *S3Store) Put(ctx context.Context, bucket string, key string, body []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+func (s *S3Store) Delete(ctx context.Context, bucket string, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
+
+// MemoryStore is an in-memory ObjectStore for tests and local runs.
+type MemoryStore struct {
+	mu      sync.RWMutex
+	objects map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{objects: make(map[string][]byte)}
+}
+
+func (m *MemoryStore) Get(ctx context.Context, bucket string, key string) ([]byte, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	body, ok := m.objects[bucket+"/"+key]
+	if !ok {
+		return nil, ErrObjectNotFound
+	}
+	return bytes.Clone(body), nil
+}
+
+func (m *MemoryStore) Put(ctx context.Context, bucket string, key string, body []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[bucket+"/"+key] = bytes.Clone(body)
+	return nil
+}
+
+func (m *MemoryStore) Delete(ctx context.Context, bucket string, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, bucket+"/"+key)
+	return nil
+}
@@ -0,0 +1,55 @@
+// Package testutil holds fixtures shared by the tests of several packages.
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+var ErrUnknownToken = errors.New("unknown token")
+
+// StubValidator accepts the tokens it holds claims for.
+type StubValidator map[string]*model.JwtClaim
+
+func (v StubValidator) ValidateClaims(token string) (*model.JwtClaim, error) {
+	claims, ok := v[token]
+	if !ok {
+		return nil, ErrUnknownToken
+	}
+	return claims, nil
+}
+
+// NewClientCert returns a self-signed client certificate, as used for RFC
+// 8705 self-signed certificate binding.
+func NewClientCert(t *testing.T, cn string) *x509.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
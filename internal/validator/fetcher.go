@@ -1,11 +1,13 @@
 package validator
 
 import (
+	"errors"
 	"fmt"
 	"sync"
 	"time"
 
 	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
 )
 
 func NewValidatorBackgroundFetcher(
@@ -17,6 +19,7 @@ func NewValidatorBackgroundFetcher(
 		fetch:  fetch,
 		ticker: time.NewTicker(refreshInterval),
 		result: result,
+		done:   make(chan struct{}),
 	}
 }
 
@@ -25,6 +28,8 @@ type ValidatorBackgroundFetcher struct {
 	fetch  func() (*model.JWKS, error)
 	ticker *time.Ticker
 	result chan *model.JWKS
+	done   chan struct{}
+	stop   sync.Once
 	wg     sync.WaitGroup
 }
 
@@ -40,20 +45,33 @@ func (b *ValidatorBackgroundFetcher) Start() {
 		defer b.ticker.Stop()
 		for {
 			select {
+			case <-b.done:
+				return
 			case <-b.ticker.C:
 				jwks, err := b.fetch()
+				if errors.Is(err, storage.ErrNotModified) {
+					continue
+				}
 				if err != nil {
 					fmt.Println("error: ", err)
 					continue
 				}
 				fmt.Println("Fetched Jwks")
-				b.result <- jwks
+				select {
+				case b.result <- jwks:
+				case <-b.done:
+					return
+				}
 			}
 		}
 	}()
 }
 
+// Stop ends the fetch loop and waits for it to exit. It is safe to call more
+// than once.
 func (b *ValidatorBackgroundFetcher) Stop() {
-	b.ticker.Stop()
+	b.stop.Do(func() {
+		close(b.done)
+	})
 	b.wg.Wait()
 }
@@ -6,15 +6,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"net/http"
 	"sync"
 	"time"
 
 	backgroundfetcher "github.com/CalvinCYCheung/go_token_validator/internal/background_fetcher"
 	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/keyevent"
 	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/CalvinCYCheung/go_token_validator/internal/revocation"
 	storage "github.com/CalvinCYCheung/go_token_validator/internal/storage"
-	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/golang-jwt/jwt/v5"
 )
@@ -23,6 +24,14 @@ type Validator interface {
 	Validate(token string) (bool, error)
 }
 
+var (
+	ErrInvalidSigningMethod = errors.New("invalid signing method")
+	ErrMissingExpiration    = errors.New("token has no expiration")
+	ErrTokenExpired         = errors.New("token is expired")
+	ErrKidNotFound          = errors.New("kid not found")
+	ErrTokenRevoked         = errors.New("token is revoked")
+)
+
 func NewRsaKeyValidator(
 	refresh time.Duration,
 	fetch func() (*model.JWKS, error),
@@ -32,15 +41,12 @@ func NewRsaKeyValidator(
 		panic(err)
 	}
 	result := make(chan *model.JWKS)
-	fetcher := &ValidatorBackgroundFetcher{
-		fetch:  fetchJwk,
-		ticker: time.NewTicker(refresh),
-		result: result,
-	}
+	fetcher := NewValidatorBackgroundFetcher(refresh, fetch, result)
 	factory := converter.ConvertFactory{}
 	converter := factory.JwkToPublicConverter()
 	validator := &RsaKeyValidator{
 		jwks:      jwks,
+		keySet:    model.NewKeySetInfo(jwks.Kids(), model.KeySetInfo{}),
 		converter: converter,
 		fetcher:   fetcher,
 	}
@@ -52,23 +58,74 @@ func NewRsaKeyValidator(
 }
 
 type RsaKeyValidator struct {
-	mu        sync.RWMutex
-	jwks      *model.JWKS
-	converter converter.Converter[*rsa.PublicKey, model.PublicKeyJWK]
-	fetcher   backgroundfetcher.BackgroundFetcher
+	mu          sync.RWMutex
+	jwks        *model.JWKS
+	keySet      model.KeySetInfo
+	converter   converter.Converter[*rsa.PublicKey, model.PublicKeyJWK]
+	fetcher     backgroundfetcher.BackgroundFetcher
+	subscribers keyevent.Subscribers
+	revocations revocation.RevocationStore
+	audience    string
+	issuer      string
+}
+
+// Close stops the background refresh of the JWKS. The validator keeps
+// validating with the keys it has.
+func (v *RsaKeyValidator) Close() {
+	v.fetcher.Stop()
+}
+
+// SetRevocationStore makes the validator reject tokens revoked in store.
+func (v *RsaKeyValidator) SetRevocationStore(store revocation.RevocationStore) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.revocations = store
+}
+
+// SetAudience makes the validator reject tokens whose aud claim does not
+// contain audience, such as tokens exchanged for another service.
+func (v *RsaKeyValidator) SetAudience(audience string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.audience = audience
+}
+
+// SetIssuer makes the validator reject tokens whose iss claim is not issuer.
+func (v *RsaKeyValidator) SetIssuer(issuer string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.issuer = issuer
+}
+
+// OnKeysChanged registers fn to be called after the JWKS gains or loses a kid.
+func (v *RsaKeyValidator) OnKeysChanged(fn keyevent.KeysChangedFunc) {
+	v.subscribers.Subscribe(fn)
 }
 
 func (v *RsaKeyValidator) Validate(token string) (bool, error) {
-	_, err := jwt.ParseWithClaims(token, &model.JwtClaim{}, func(t *jwt.Token) (any, error) {
+	_, err := v.ValidateClaims(token)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ValidateClaims verifies the token and returns its claims.
+func (v *RsaKeyValidator) ValidateClaims(token string) (*model.JwtClaim, error) {
+	claims := &model.JwtClaim{}
+	_, err := jwt.ParseWithClaims(token, claims, func(t *jwt.Token) (any, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, errors.New("invalid signing method")
+			return nil, ErrInvalidSigningMethod
 		}
 		exp, err := t.Claims.GetExpirationTime()
 		if err != nil {
 			return nil, err
 		}
+		if exp == nil {
+			return nil, ErrMissingExpiration
+		}
 		if time.Now().After(exp.Time) {
-			return nil, errors.New("token is expired")
+			return nil, ErrTokenExpired
 		}
 		jwks := v.getJwks()
 		for _, pkj := range jwks.Keys {
@@ -80,12 +137,39 @@ func (v *RsaKeyValidator) Validate(token string) (bool, error) {
 				return pk, nil
 			}
 		}
-		return nil, errors.New("kid not found")
-	})
+		return nil, ErrKidNotFound
+	}, v.parserOptions()...)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
-	return true, nil
+	v.mu.RLock()
+	store := v.revocations
+	v.mu.RUnlock()
+	if store != nil {
+		revoked, err := store.IsRevoked(context.Background(), claims)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, ErrTokenRevoked
+		}
+	}
+	return claims, nil
+}
+
+// parserOptions returns the aud and iss checks the validator is configured
+// with.
+func (v *RsaKeyValidator) parserOptions() []jwt.ParserOption {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	var options []jwt.ParserOption
+	if v.audience != "" {
+		options = append(options, jwt.WithAudience(v.audience))
+	}
+	if v.issuer != "" {
+		options = append(options, jwt.WithIssuer(v.issuer))
+	}
+	return options
 }
 
 func (v *RsaKeyValidator) backgroundUpdates(result chan *model.JWKS) {
@@ -103,31 +187,73 @@ func (v *RsaKeyValidator) getJwks() *model.JWKS {
 	return v.jwks
 }
 
+// UpdateJwks replaces the keys at once instead of at the next refresh, as
+// when the issuer in the same process rotates its signing key.
+func (v *RsaKeyValidator) UpdateJwks(jwks *model.JWKS) {
+	v.updateJwks(jwks)
+}
+
 func (v *RsaKeyValidator) updateJwks(jwks *model.JWKS) {
 	v.mu.Lock()
-	defer v.mu.Unlock()
+	old := v.keySet
 	v.jwks = jwks
+	v.keySet = model.NewKeySetInfo(jwks.Kids(), old)
+	current := v.keySet
+	v.mu.Unlock()
+	if current.Changed() {
+		v.subscribers.Notify(old, current)
+	}
 }
 
-func fetchJwk() (*model.JWKS, error) {
-	ctx := context.Background()
-
-	client := storage.InitS3Client()
-	res, err := client.GetObject(ctx, &s3.GetObjectInput{
-		Key:    aws.String(".well-known/jwks.json"),
-		Bucket: aws.String("goback-end-shared-bucket"),
-	})
-	if err != nil {
-		return nil, err
-	}
-	body, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, err
+// NewS3JwksFetch returns a fetch function that reads the JWKS from S3 and
+// returns storage.ErrNotModified while the object is unchanged.
+func NewS3JwksFetch(client *s3.Client) func() (*model.JWKS, error) {
+	object := storage.NewConditionalObject(client, "goback-end-shared-bucket", ".well-known/jwks.json")
+	return func() (*model.JWKS, error) {
+		body, err := object.Get(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		var jwks model.JWKS
+		err = json.Unmarshal(body, &jwks)
+		if err != nil {
+			object.Invalidate()
+			return nil, err
+		}
+		return &jwks, nil
 	}
-	var jwks model.JWKS
-	err = json.Unmarshal(body, &jwks)
-	if err != nil {
-		return nil, err
+}
+
+// NewHttpJwksFetch returns a fetch function that reads a JWKS from url and
+// returns storage.ErrNotModified while the server reports it unchanged.
+func NewHttpJwksFetch(url string, client *http.Client) func() (*model.JWKS, error) {
+	var etag string
+	return func() (*model.JWKS, error) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		res, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer res.Body.Close()
+		if res.StatusCode == http.StatusNotModified {
+			return nil, storage.ErrNotModified
+		}
+		if res.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("jwks endpoint returned %s", res.Status)
+		}
+		var jwks model.JWKS
+		err = json.NewDecoder(res.Body).Decode(&jwks)
+		if err != nil {
+			return nil, err
+		}
+		etag = res.Header.Get("ETag")
+		return &jwks, nil
 	}
-	return &jwks, nil
 }
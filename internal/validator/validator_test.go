@@ -0,0 +1,61 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestValidateClaimsChecksAudienceAndIssuer(t *testing.T) {
+	key := newRsaKey(t)
+	jwk, err := converter.PublicKeyToJwkConverter{}.Convert(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk.Kid = "test"
+	v := NewRsaKeyValidator(time.Hour, func() (*model.JWKS, error) {
+		return &model.JWKS{Keys: []model.PublicKeyJWK{jwk}}, nil
+	})
+	v.SetAudience("billing")
+	v.SetIssuer("https://issuer.example")
+
+	sign := func(audience string, issuer string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+			Subject:   "user",
+			Audience:  jwt.ClaimStrings{audience},
+			Issuer:    issuer,
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		})
+		token.Header["kid"] = jwk.Kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	tests := []struct {
+		name     string
+		audience string
+		issuer   string
+		wantErr  error
+	}{
+		{"expected", "billing", "https://issuer.example", nil},
+		{"other audience", "orders", "https://issuer.example", jwt.ErrTokenInvalidAudience},
+		{"other issuer", "billing", "https://other.example", jwt.ErrTokenInvalidIssuer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.ValidateClaims(sign(tt.audience, tt.issuer))
+			if tt.wantErr == nil && err != nil {
+				t.Fatal(err)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package validator
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+var ErrX5cMismatch = errors.New("x5c leaf certificate does not match jwk")
+
+// X5cVerifier checks that the x5c chain of each JWK ends in a trusted root and
+// that the leaf certificate carries the same key as the JWK.
+type X5cVerifier struct {
+	roots *x509.CertPool
+	// RequireX5c rejects keys that have no certificate chain.
+	RequireX5c bool
+	now        func() time.Time
+}
+
+func NewX5cVerifier(roots *x509.CertPool, requireX5c bool) *X5cVerifier {
+	return &X5cVerifier{
+		roots:      roots,
+		RequireX5c: requireX5c,
+		now:        time.Now,
+	}
+}
+
+func (v *X5cVerifier) VerifyJwks(jwks *model.JWKS) error {
+	for _, key := range jwks.Keys {
+		err := v.Verify(key)
+		if err != nil {
+			return fmt.Errorf("kid %s: %w", key.Kid, err)
+		}
+	}
+	return nil
+}
+
+func (v *X5cVerifier) Verify(jwk model.PublicKeyJWK) error {
+	if len(jwk.X5c) == 0 {
+		if v.RequireX5c {
+			return errors.New("x5c is required")
+		}
+		return nil
+	}
+	certs := make([]*x509.Certificate, 0, len(jwk.X5c))
+	for _, encoded := range jwk.X5c {
+		der, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return err
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return err
+		}
+		certs = append(certs, cert)
+	}
+	leaf := certs[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   v.now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return err
+	}
+	if jwk.X5t != "" {
+		sum := sha1.Sum(leaf.Raw)
+		if jwk.X5t != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return errors.New("x5t does not match x5c leaf certificate")
+		}
+	}
+	if jwk.X5tS256 != "" {
+		sum := sha256.Sum256(leaf.Raw)
+		if jwk.X5tS256 != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return errors.New("x5t#S256 does not match x5c leaf certificate")
+		}
+	}
+	return matchLeafKey(leaf, jwk)
+}
+
+func matchLeafKey(leaf *x509.Certificate, jwk model.PublicKeyJWK) error {
+	var leafJwk model.PublicKeyJWK
+	var err error
+	switch key := leaf.PublicKey.(type) {
+	case *rsa.PublicKey:
+		leafJwk, err = converter.PublicKeyToJwkConverter{}.Convert(key)
+	case *ecdsa.PublicKey:
+		leafJwk, err = converter.EcdsaPublicKeyToJwkConverter{}.Convert(key)
+	case ed25519.PublicKey:
+		leafJwk, err = converter.Ed25519PublicKeyToJwkConverter{}.Convert(key)
+	default:
+		return fmt.Errorf("unsupported certificate key type %T", key)
+	}
+	if err != nil {
+		return err
+	}
+	thumbprint, err := jwk.Thumbprint()
+	if err != nil {
+		return err
+	}
+	if thumbprint != leafJwk.Kid {
+		return ErrX5cMismatch
+	}
+	return nil
+}
+
+// NewX5cRsaKeyValidator returns a validator that only takes keys from a JWKS
+// whose x5c chains pass verifier. A JWKS that fails on refresh is dropped and
+// the previous keys stay in use.
+func NewX5cRsaKeyValidator(
+	refresh time.Duration,
+	fetch func() (*model.JWKS, error),
+	verifier *X5cVerifier,
+) *RsaKeyValidator {
+	return NewRsaKeyValidator(refresh, WithX5cVerification(fetch, verifier))
+}
+
+// WithX5cVerification wraps fetch so that a JWKS failing verification is
+// returned as an error and never reaches the validator.
+func WithX5cVerification(
+	fetch func() (*model.JWKS, error),
+	verifier *X5cVerifier,
+) func() (*model.JWKS, error) {
+	return func() (*model.JWKS, error) {
+		jwks, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+		err = verifier.VerifyJwks(jwks)
+		if err != nil {
+			return nil, err
+		}
+		return jwks, nil
+	}
+}
@@ -0,0 +1,206 @@
+package validator
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/converter"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  crypto.Signer
+}
+
+func newTestCert(t *testing.T, cn string, key crypto.Signer, parent *testCert) *testCert {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil || cn != "leaf",
+	}
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+func newEcKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newRsaKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := converter.GenerateRsaKey(converter.MinRsaKeyBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// chain is a root CA, an intermediate CA and an RSA signing key with a leaf
+// certificate issued by the intermediate.
+type chain struct {
+	roots      *x509.CertPool
+	signingKey *rsa.PrivateKey
+	jwk        model.PublicKeyJWK
+}
+
+func newChain(t *testing.T) chain {
+	t.Helper()
+	root := newTestCert(t, "root", newEcKey(t), nil)
+	intermediate := newTestCert(t, "intermediate", newEcKey(t), root)
+	signingKey := newRsaKey(t)
+	leaf := newTestCert(t, "leaf", signingKey, intermediate)
+	jwk, err := converter.PublicKeyToJwkConverter{}.Convert(&signingKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk.X5c = []string{
+		base64.StdEncoding.EncodeToString(leaf.cert.Raw),
+		base64.StdEncoding.EncodeToString(intermediate.cert.Raw),
+	}
+	sum := sha256.Sum256(leaf.cert.Raw)
+	jwk.X5tS256 = base64.RawURLEncoding.EncodeToString(sum[:])
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+	return chain{roots: roots, signingKey: signingKey, jwk: jwk}
+}
+
+func TestX5cVerifier(t *testing.T) {
+	valid := newChain(t)
+	other := newChain(t)
+	swapped := valid.jwk
+	attackerJwk, err := converter.PublicKeyToJwkConverter{}.Convert(&newRsaKey(t).PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped.N = attackerJwk.N
+	badThumbprint := valid.jwk
+	badThumbprint.X5tS256 = other.jwk.X5tS256
+	missingIntermediate := valid.jwk
+	missingIntermediate.X5c = valid.jwk.X5c[:1]
+	noChain := valid.jwk
+	noChain.X5c = nil
+	noChain.X5tS256 = ""
+
+	tests := []struct {
+		name    string
+		roots   *x509.CertPool
+		require bool
+		jwk     model.PublicKeyJWK
+		wantErr bool
+	}{
+		{name: "valid chain", roots: valid.roots, jwk: valid.jwk},
+		{name: "swapped key", roots: valid.roots, jwk: swapped, wantErr: true},
+		{name: "untrusted root", roots: other.roots, jwk: valid.jwk, wantErr: true},
+		{name: "x5t#S256 mismatch", roots: valid.roots, jwk: badThumbprint, wantErr: true},
+		{name: "missing intermediate", roots: valid.roots, jwk: missingIntermediate, wantErr: true},
+		{name: "no chain allowed", roots: valid.roots, jwk: noChain},
+		{name: "no chain required", roots: valid.roots, require: true, jwk: noChain, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewX5cVerifier(tt.roots, tt.require).Verify(tt.jwk)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if err := NewX5cVerifier(valid.roots, false).Verify(swapped); !errors.Is(err, ErrX5cMismatch) {
+		t.Errorf("swapped key error = %v, want ErrX5cMismatch", err)
+	}
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, kid string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.RegisteredClaims{
+		Subject:   "user",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	})
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestX5cRsaKeyValidatorKeepsKeysOnTamperedJwks(t *testing.T) {
+	valid := newChain(t)
+	var mu sync.Mutex
+	jwks := &model.JWKS{Keys: []model.PublicKeyJWK{valid.jwk}}
+	// fetched signals the background fetches that a test is waiting for.
+	fetched := make(chan struct{})
+	fetch := func() (*model.JWKS, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		select {
+		case fetched <- struct{}{}:
+		default:
+		}
+		return jwks, nil
+	}
+	v := NewX5cRsaKeyValidator(10*time.Millisecond, fetch, NewX5cVerifier(valid.roots, true))
+
+	_, err := v.ValidateClaims(signTestToken(t, valid.signingKey, valid.jwk.Kid))
+	if err != nil {
+		t.Fatalf("token of the certified key: %v", err)
+	}
+
+	// An attacker swaps in their own key under the certified kid and chain.
+	attackerKey := newRsaKey(t)
+	attackerJwk, err := converter.PublicKeyToJwkConverter{}.Convert(&attackerKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := valid.jwk
+	tampered.N = attackerJwk.N
+	mu.Lock()
+	jwks = &model.JWKS{Keys: []model.PublicKeyJWK{tampered}}
+	mu.Unlock()
+	// The second fetch starts after the tampered JWKS was handled.
+	<-fetched
+	<-fetched
+
+	_, err = v.ValidateClaims(signTestToken(t, attackerKey, valid.jwk.Kid))
+	if err == nil {
+		t.Fatal("token signed with the swapped key was accepted")
+	}
+	_, err = v.ValidateClaims(signTestToken(t, valid.signingKey, valid.jwk.Kid))
+	if err != nil {
+		t.Fatalf("token of the certified key after a tampered refresh: %v", err)
+	}
+}
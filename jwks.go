@@ -0,0 +1,18 @@
+package tokenservice
+
+import (
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/jwks"
+)
+
+// JwksPath is where the JWKS is conventionally served.
+const JwksPath = jwks.Path
+
+type JwksHandler = jwks.Handler
+
+// NewJwksHandler returns an http.Handler publishing the public keys of
+// generator, cacheable for maxAge and revalidated by ETag.
+func NewJwksHandler(generator *TokenGeneratorImpl, maxAge time.Duration) *JwksHandler {
+	return jwks.NewHandler(generator, maxAge)
+}
@@ -0,0 +1,14 @@
+package tokenservice
+
+import (
+	"github.com/CalvinCYCheung/go_token_validator/internal/keyevent"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+// KeySetInfo describes the kids of a key set and how they differ from the
+// previous key set.
+type KeySetInfo = model.KeySetInfo
+
+// KeysChangedFunc is called by OnKeysChanged of RsaKeyValidator and
+// TokenGeneratorImpl after a rotation.
+type KeysChangedFunc = keyevent.KeysChangedFunc
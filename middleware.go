@@ -0,0 +1,34 @@
+package tokenservice
+
+import (
+	"context"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/middleware"
+	"github.com/CalvinCYCheung/go_token_validator/internal/model"
+)
+
+type JwtClaim = model.JwtClaim
+
+type Authenticator = middleware.Authenticator
+
+type Extractor = middleware.Extractor
+
+// NewAuthenticator returns net/http middleware that authenticates tokens with
+// validator, read by extractors or from the Authorization Bearer header.
+func NewAuthenticator(validator *RsaKeyValidator, realm string, extractors ...Extractor) *Authenticator {
+	return middleware.NewAuthenticator(validator, realm, extractors...)
+}
+
+func ClaimsFromContext(ctx context.Context) (*JwtClaim, bool) {
+	return middleware.ClaimsFromContext(ctx)
+}
+
+var (
+	BearerHeader      = middleware.BearerHeader
+	HeaderExtractor   = middleware.Header
+	CookieExtractor   = middleware.Cookie
+	QueryExtractor    = middleware.QueryParam
+	WebSocketProtocol = middleware.WebSocketProtocol
+	FormExtractor     = middleware.FormField
+	ChainExtractors   = middleware.Chain
+)
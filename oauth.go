@@ -0,0 +1,34 @@
+package tokenservice
+
+import (
+	"time"
+
+	"github.com/CalvinCYCheung/go_token_validator/internal/oauth"
+)
+
+type ClientCredentialsSource = oauth.ClientCredentialsSource
+
+// NewClientCredentialsSource returns a token source that fetches tokens from
+// tokenUrl with the client_credentials grant and caches them.
+func NewClientCredentialsSource(tokenUrl string, clientID string, clientSecret string, scopes ...string) *ClientCredentialsSource {
+	return oauth.NewClientCredentialsSource(tokenUrl, clientID, clientSecret, scopes...)
+}
+
+type IntrospectionValidator = oauth.IntrospectionValidator
+
+// NewIntrospectionValidator returns a validator that checks tokens at an RFC
+// 7662 introspection endpoint, authenticating as the given client, and
+// caches the answers for up to cacheTTL.
+func NewIntrospectionValidator(introspectionUrl string, clientID string, clientSecret string, cacheTTL time.Duration) *IntrospectionValidator {
+	return oauth.NewIntrospectionValidator(introspectionUrl, clientID, clientSecret, cacheTTL)
+}
+
+type Exchanger = oauth.Exchanger
+
+type ExchangeRequest = oauth.ExchangeRequest
+
+// NewExchanger returns an RFC 8693 token exchanger that validates subject
+// tokens with validator and mints the exchanged tokens with generator.
+func NewExchanger(validator *RsaKeyValidator, generator *TokenGeneratorImpl) *Exchanger {
+	return oauth.NewExchanger(validator, generator)
+}
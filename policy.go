@@ -0,0 +1,43 @@
+package tokenservice
+
+import (
+	"github.com/CalvinCYCheung/go_token_validator/internal/policy"
+)
+
+type Policy = policy.Policy
+
+type PolicyRule = policy.Rule
+
+type PolicyRuleFunc = policy.RuleFunc
+
+type PolicyInput = policy.Input
+
+type PolicyViolation = policy.Violation
+
+type PolicyValidator = policy.Validator
+
+// NewPolicy returns a policy that requires every rule to pass.
+func NewPolicy(rules ...PolicyRule) *Policy {
+	return policy.New(rules...)
+}
+
+// LoadPolicyFile loads the named policies of a YAML or JSON file.
+func LoadPolicyFile(path string) (map[string]*Policy, error) {
+	return policy.LoadFile(path)
+}
+
+// NewPolicyValidator returns a validator that also evaluates p against the
+// claims of every valid token.
+func NewPolicyValidator(validator *RsaKeyValidator, p *Policy) *PolicyValidator {
+	return policy.NewValidator(validator, p)
+}
+
+var (
+	ClaimExists      = policy.Exists
+	ClaimEquals      = policy.Equals
+	ClaimEqualsParam = policy.EqualsParam
+	ClaimContains    = policy.Contains
+	ClaimOneOf       = policy.OneOf
+	AllRules         = policy.All
+	AnyRule          = policy.Any
+)
@@ -0,0 +1,20 @@
+package tokenservice
+
+import (
+	"github.com/CalvinCYCheung/go_token_validator/internal/refresh"
+)
+
+type RefreshIssuer = refresh.Issuer
+
+type TokenPair = refresh.TokenPair
+
+type RefreshStore = refresh.Store
+
+// NewRefreshIssuer returns an issuer of refresh tokens for generator, backed
+// by store or by an in-memory store when store is nil.
+func NewRefreshIssuer(generator *TokenGeneratorImpl, store RefreshStore) *RefreshIssuer {
+	if store == nil {
+		store = refresh.NewMemoryStore()
+	}
+	return refresh.NewIssuer(generator, store, refresh.DefaultTTL)
+}
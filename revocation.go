@@ -0,0 +1,21 @@
+package tokenservice
+
+import (
+	"github.com/CalvinCYCheung/go_token_validator/internal/generator"
+	"github.com/CalvinCYCheung/go_token_validator/internal/revocation"
+)
+
+type RevocationStore = revocation.RevocationStore
+
+// NewMemoryRevocationStore returns an in-memory store for tokens issued by
+// this package.
+func NewMemoryRevocationStore() *revocation.MemoryStore {
+	return revocation.NewMemoryStore(generator.TokenTTL)
+}
+
+// NewFileRevocationStore returns a store persisted to path.
+func NewFileRevocationStore(path string) (*revocation.FileStore, error) {
+	return revocation.NewFileStore(path, generator.TokenTTL)
+}
+
+var RevokeClaims = revocation.RevokeClaims